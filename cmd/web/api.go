@@ -0,0 +1,399 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"vincellauderes.net/snippetbox/pkg/forms"
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+// The /api/v1 handlers mirror the HTML ones but speak JSON. Successful
+// responses wrap their payload in an object keyed by what it is, like
+// {"snippet": {...}}, and every error uses the same envelope:
+//
+//	{"error": {"status": 422, "message": "...", "fields": {"title": ["..."]}}}
+//
+// where fields is only present for validation errors.
+
+// maxAPIBodySize caps the size of JSON request bodies.
+const maxAPIBodySize = 1 << 20
+
+// apiSnippet is the JSON representation of a snippet.
+type apiSnippet struct {
+	ID       int        `json:"id"`
+	Title    string     `json:"title"`
+	Content  string     `json:"content"`
+	Language string     `json:"language"`
+	Tags     []string   `json:"tags,omitempty"`
+	Author   *apiAuthor `json:"author"`
+	Created  time.Time  `json:"created"`
+	Expires  time.Time  `json:"expires"`
+}
+
+// apiAuthor identifies who wrote a snippet. It is null for snippets created
+// before authorship was recorded.
+type apiAuthor struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// apiUser is the JSON representation of a user. It never includes the
+// password hash.
+type apiUser struct {
+	ID      int       `json:"id"`
+	Name    string    `json:"name"`
+	Email   string    `json:"email"`
+	Created time.Time `json:"created"`
+}
+
+// apiSnippetInput is the request body for creating or updating a snippet.
+// Expires is a number of days, one of 1, 7 or 365. An update may leave it
+// out to keep the snippet's current expiry.
+type apiSnippetInput struct {
+	Title    string   `json:"title"`
+	Content  string   `json:"content"`
+	Language string   `json:"language"`
+	Expires  int      `json:"expires"`
+	Tags     []string `json:"tags"`
+}
+
+// apiError is the body of every error response.
+type apiError struct {
+	Status  int                 `json:"status"`
+	Message string              `json:"message"`
+	Fields  map[string][]string `json:"fields,omitempty"`
+}
+
+func newAPISnippet(s *models.Snippet) apiSnippet {
+	as := apiSnippet{
+		ID:       s.ID,
+		Title:    s.Title,
+		Content:  s.Content,
+		Language: s.Language,
+		Tags:     s.Tags,
+		Created:  s.Created,
+		Expires:  s.Expires,
+	}
+	if s.UserID != 0 {
+		as.Author = &apiAuthor{ID: s.UserID, Name: s.Author}
+	}
+	return as
+}
+
+// apiListSnippets returns a page of unexpired snippets. It takes the same
+// query string parameters as the /snippets page, and the next and prev
+// cursors go in the after and before parameters respectively.
+func (app *application) apiListSnippets(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	f, ok := snippetFilter(form)
+	if !ok {
+		app.apiValidationError(w, form)
+		return
+	}
+
+	page, err := app.snippets.List(f)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	resp := struct {
+		Snippets []apiSnippet `json:"snippets"`
+		Next     *string      `json:"next"`
+		Prev     *string      `json:"prev"`
+	}{
+		Snippets: make([]apiSnippet, len(page.Snippets)),
+	}
+	for i, s := range page.Snippets {
+		resp.Snippets[i] = newAPISnippet(s)
+	}
+	if page.Next != nil {
+		next := page.Next.String()
+		resp.Next = &next
+	}
+	if page.Prev != nil {
+		prev := page.Prev.String()
+		resp.Prev = &prev
+	}
+
+	app.writeJSON(w, http.StatusOK, resp)
+}
+
+func (app *application) apiGetSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.apiLookupSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippet": newAPISnippet(s)})
+}
+
+func (app *application) apiCreateSnippet(w http.ResponseWriter, r *http.Request) {
+	form, ok := app.readSnippetInput(w, r, false)
+	if !ok {
+		return
+	}
+
+	userID := app.authenticatedUser(r).ID
+	id, err := app.snippets.Insert(userID, form.Get("title"), form.Get("content"), snippetLanguage(form), form.Get("expires"), snippetTags(form))
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	s, err := app.snippets.Get(id)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	s.Tags = snippetTags(form)
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%d", id))
+	app.writeJSON(w, http.StatusCreated, map[string]any{"snippet": newAPISnippet(s)})
+}
+
+// apiUpdateSnippet replaces a snippet's fields. Like the edit page, only the
+// author may do so.
+func (app *application) apiUpdateSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.apiOwnSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	form, ok := app.readSnippetInput(w, r, true)
+	if !ok {
+		return
+	}
+
+	expires := form.Get("expires")
+	if expires == keepExpiry {
+		expires = ""
+	}
+
+	userID := app.authenticatedUser(r).ID
+	err := app.snippets.Update(s.ID, userID, form.Get("title"), form.Get("content"), snippetLanguage(form), expires, snippetTags(form))
+	if err == models.ErrNoRecord {
+		app.apiNotFound(w)
+		return
+	} else if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	s, err = app.snippets.Get(s.ID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+	s.Tags = snippetTags(form)
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippet": newAPISnippet(s)})
+}
+
+func (app *application) apiDeleteSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.apiOwnSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	err := app.snippets.Delete(s.ID)
+	if err != nil && err != models.ErrNoRecord {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiCurrentUser returns the user the request is authenticated as.
+func (app *application) apiCurrentUser(w http.ResponseWriter, r *http.Request) {
+	u := app.authenticatedUser(r)
+	app.writeJSON(w, http.StatusOK, map[string]any{"user": apiUser{
+		ID:      u.ID,
+		Name:    u.Name,
+		Email:   u.Email,
+		Created: u.Created,
+	}})
+}
+
+// apiNotFound is the API counterpart of notFound.
+func (app *application) apiNotFound(w http.ResponseWriter) {
+	app.apiError(w, http.StatusNotFound, "The requested resource could not be found")
+}
+
+// requireAPIUser is the API counterpart of requireAuthenticatedUser. It
+// responds with 401 Unauthorized instead of redirecting to the login page.
+func (app *application) requireAPIUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if app.authenticatedUser(r) == nil {
+			app.apiError(w, http.StatusUnauthorized, "You must be authenticated to access this resource")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readSnippetInput decodes a snippet from the JSON request body and
+// validates it with the same rules as the HTML forms. When update is true a
+// missing expires becomes keepExpiry, like on the edit page. If anything is
+// wrong it sends the error response and returns false.
+func (app *application) readSnippetInput(w http.ResponseWriter, r *http.Request, update bool) (*forms.Form, bool) {
+	var in apiSnippetInput
+	if err := readJSON(w, r, &in); err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	data := url.Values{
+		"title":    {in.Title},
+		"content":  {in.Content},
+		"language": {in.Language},
+		"tags":     {strings.Join(in.Tags, ",")},
+	}
+	if in.Expires != 0 {
+		data.Set("expires", strconv.Itoa(in.Expires))
+	} else if update {
+		data.Set("expires", keepExpiry)
+	}
+
+	form := forms.New(data)
+	if update {
+		validateSnippetForm(form, keepExpiry)
+	} else {
+		validateSnippetForm(form)
+	}
+	if !form.Valid() {
+		app.apiValidationError(w, form)
+		return nil, false
+	}
+
+	return form, true
+}
+
+// apiLookupSnippet is the API counterpart of lookupSnippet.
+func (app *application) apiLookupSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.apiNotFound(w)
+		return nil
+	}
+
+	s, err := app.snippets.Get(id)
+	if err == models.ErrNoRecord {
+		app.apiNotFound(w)
+		return nil
+	} else if err != nil {
+		app.apiServerError(w, err)
+		return nil
+	}
+
+	s.Tags, err = app.tags.ForSnippet(s.ID)
+	if err != nil {
+		app.apiServerError(w, err)
+		return nil
+	}
+
+	return s
+}
+
+// apiOwnSnippet is the API counterpart of ownSnippet.
+func (app *application) apiOwnSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	s := app.apiLookupSnippet(w, r)
+	if s == nil {
+		return nil
+	}
+
+	if s.UserID != app.authenticatedUser(r).ID {
+		app.apiError(w, http.StatusForbidden, "Only the author can change this snippet")
+		return nil
+	}
+
+	return s
+}
+
+// readJSON decodes a single JSON object from the request body into dst,
+// rejecting unknown fields and bodies which are too large, and turns the
+// decoder's errors into messages fit for the client.
+func readJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIBodySize))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err != nil {
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		var maxBytesErr *http.MaxBytesError
+
+		switch {
+		case errors.As(err, &syntaxErr):
+			return fmt.Errorf("Body contains badly-formed JSON (at character %d)", syntaxErr.Offset)
+		case errors.Is(err, io.ErrUnexpectedEOF):
+			return errors.New("Body contains badly-formed JSON")
+		case errors.As(err, &typeErr):
+			return fmt.Errorf("Body contains the wrong type for the %q field", typeErr.Field)
+		case errors.Is(err, io.EOF):
+			return errors.New("Body must not be empty")
+		case strings.HasPrefix(err.Error(), "json: unknown field "):
+			return fmt.Errorf("Body contains unknown field %s", strings.TrimPrefix(err.Error(), "json: unknown field "))
+		case errors.As(err, &maxBytesErr):
+			return fmt.Errorf("Body must not be larger than %d bytes", maxBytesErr.Limit)
+		}
+		return err
+	}
+
+	if dec.More() {
+		return errors.New("Body must only contain a single JSON object")
+	}
+
+	return nil
+}
+
+// writeJSON sends v as a JSON response with the given status code.
+func (app *application) writeJSON(w http.ResponseWriter, status int, v any) {
+	b, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+	w.Write([]byte("\n"))
+}
+
+// apiError sends an error envelope with the given status code and message.
+func (app *application) apiError(w http.ResponseWriter, status int, message string) {
+	app.writeJSON(w, status, map[string]apiError{"error": {Status: status, Message: message}})
+}
+
+// apiValidationError sends a 422 Unprocessable Entity response listing the
+// errors recorded in a form, keyed by field.
+func (app *application) apiValidationError(w http.ResponseWriter, form *forms.Form) {
+	status := http.StatusUnprocessableEntity
+	app.writeJSON(w, status, map[string]apiError{"error": {
+		Status:  status,
+		Message: "The request failed validation",
+		Fields:  form.Errors,
+	}})
+}
+
+// apiServerError is the API counterpart of serverError. It logs the error
+// with a stack trace and sends a generic 500 response.
+func (app *application) apiServerError(w http.ResponseWriter, err error) {
+	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+	app.errorLog.Output(2, trace)
+
+	status := http.StatusInternalServerError
+	app.apiError(w, status, "The server encountered a problem and could not process your request")
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+// apiErrorEnvelope is the body of an API error response.
+type apiErrorEnvelope struct {
+	Error apiError `json:"error"`
+}
+
+func TestAPIErrorEnvelopes(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	if err := app.userss.Insert("Bob", "bob@example.com", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	bobID, err := app.userss.Authenticate("bob@example.com", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := app.snippets.Insert(bobID, "Bob's", "Content", "", "7", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snippetPath := "/api/v1/snippets/" + strconv.Itoa(id)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantCode   int
+		wantFields []string
+	}{
+		{"Missing snippet", "GET", "/api/v1/snippets/999", "", http.StatusNotFound, nil},
+		{"Malformed ID", "GET", "/api/v1/snippets/abc", "", http.StatusNotFound, nil},
+		{"Another user's snippet", "DELETE", snippetPath, "", http.StatusForbidden, nil},
+		{"Badly-formed JSON", "POST", "/api/v1/snippets", `{"title": `, http.StatusBadRequest, nil},
+		{"Unknown field", "POST", "/api/v1/snippets", `{"colour": "red"}`, http.StatusBadRequest, nil},
+		{"Two objects", "POST", "/api/v1/snippets", `{} {}`, http.StatusBadRequest, nil},
+		{"Failed validation", "POST", "/api/v1/snippets", `{"expires": 3}`, http.StatusUnprocessableEntity, []string{"content", "expires", "title"}},
+	}
+
+	check := func(t *testing.T, method, path, body string, wantCode int, wantFields []string) {
+		t.Helper()
+
+		header := http.Header{"Content-Type": {"application/json"}, "X-CSRF-Token": {ts.csrfToken(t)}}
+		rs, got := ts.do(t, method, path, strings.NewReader(body), header)
+		if rs.StatusCode != wantCode {
+			t.Fatalf("want %d; got %d: %s", wantCode, rs.StatusCode, got)
+		}
+		if ct := rs.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("want Content-Type application/json; got %q", ct)
+		}
+
+		var envelope apiErrorEnvelope
+		if err := json.Unmarshal([]byte(got), &envelope); err != nil {
+			t.Fatalf("body isn't an error envelope: %v: %s", err, got)
+		}
+		if envelope.Error.Status != wantCode {
+			t.Errorf("want status %d in the envelope; got %d", wantCode, envelope.Error.Status)
+		}
+		if envelope.Error.Message == "" {
+			t.Error("want a message in the envelope")
+		}
+		for _, field := range wantFields {
+			if len(envelope.Error.Fields[field]) == 0 {
+				t.Errorf("want an error for the %q field; got %v", field, envelope.Error.Fields)
+			}
+		}
+		if wantFields == nil && envelope.Error.Fields != nil {
+			t.Errorf("want no field errors; got %v", envelope.Error.Fields)
+		}
+	}
+
+	t.Run("Not logged in", func(t *testing.T) {
+		check(t, "GET", "/api/v1/user", "", http.StatusUnauthorized, nil)
+		check(t, "POST", "/api/v1/snippets", `{"title": "x"}`, http.StatusUnauthorized, nil)
+	})
+
+	ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check(t, tt.method, tt.path, tt.body, tt.wantCode, tt.wantFields)
+		})
+	}
+}
+
+func TestAPIUpdateSnippetKeepsExpiry(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	userID := ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+	id, err := app.snippets.Insert(userID, "Haiku", "An old silent pond", "", "365", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := app.snippets.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := "/api/v1/snippets/" + strconv.Itoa(id)
+	header := http.Header{"Content-Type": {"application/json"}, "X-CSRF-Token": {ts.csrfToken(t)}}
+
+	rs, body := ts.do(t, "PUT", path, strings.NewReader(`{"title": "Haiku 2", "content": "A frog jumps"}`), header)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatalf("want %d; got %d: %s", http.StatusOK, rs.StatusCode, body)
+	}
+	s, err := app.snippets.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Title != "Haiku 2" || !s.Expires.Equal(before.Expires) {
+		t.Errorf("want title %q expiring %v; got %q expiring %v", "Haiku 2", before.Expires, s.Title, s.Expires)
+	}
+
+	rs, body = ts.do(t, "PUT", path, strings.NewReader(`{"title": "Haiku 3", "content": "A frog jumps", "expires": 1}`), header)
+	if rs.StatusCode != http.StatusOK {
+		t.Fatalf("want %d; got %d: %s", http.StatusOK, rs.StatusCode, body)
+	}
+	s, err = app.snippets.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Expires.Before(before.Expires) {
+		t.Errorf("want the expiry moved to a day from now; got %v", s.Expires)
+	}
+
+	// Creating a snippet still needs an expiry.
+	rs, body = ts.do(t, "POST", "/api/v1/snippets", strings.NewReader(`{"title": "New", "content": "Content"}`), header)
+	if rs.StatusCode != http.StatusUnprocessableEntity || !strings.Contains(body, `"expires"`) {
+		t.Errorf("create without expires: got %d: %s", rs.StatusCode, body)
+	}
+}
+
+// newAPIToken creates a user and an API token with the given scope for them,
+// returning the user's ID and the token.
+func newAPIToken(t *testing.T, app *application, email, scope string) (int, string) {
+	t.Helper()
+
+	if err := app.userss.Insert("API user", email, "password123"); err != nil {
+		t.Fatal(err)
+	}
+	userID, err := app.userss.Authenticate(email, "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := app.tokens.Insert(userID, "test", scope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return userID, token
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	userID, readToken := newAPIToken(t, app, "alice@example.com", models.ScopeRead)
+	id, err := app.snippets.Insert(userID, "Haiku", "An old silent pond", "", "7", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snippetPath := "/api/v1/snippets/" + strconv.Itoa(id)
+	snippetJSON := `{"title": "Haiku", "content": "A frog jumps", "expires": 7}`
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		token       string
+		contentType string
+		body        string
+		wantCode    int
+	}{
+		{"List", "GET", "/api/v1/snippets", readToken, "", "", http.StatusOK},
+		{"Get", "GET", snippetPath, readToken, "", "", http.StatusOK},
+		{"Current user", "GET", "/api/v1/user", readToken, "", "", http.StatusOK},
+		{"Create", "POST", "/api/v1/snippets", readToken, "application/json", snippetJSON, http.StatusForbidden},
+		{"Update", "PUT", snippetPath, readToken, "application/json", snippetJSON, http.StatusForbidden},
+		{"Delete", "DELETE", snippetPath, readToken, "", "", http.StatusForbidden},
+		{"Paste", "POST", "/paste", readToken, "text/plain", "hello", http.StatusForbidden},
+		{"Invalid token", "GET", snippetPath, "not-a-token", "", "", http.StatusUnauthorized},
+		{"Invalid token pasting", "POST", "/paste", "not-a-token", "text/plain", "hello", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{"Authorization": {"Bearer " + tt.token}}
+			if tt.contentType != "" {
+				header.Set("Content-Type", tt.contentType)
+			}
+
+			rs, body := ts.do(t, tt.method, tt.path, strings.NewReader(tt.body), header)
+			if rs.StatusCode != tt.wantCode {
+				t.Fatalf("want %d; got %d: %s", tt.wantCode, rs.StatusCode, body)
+			}
+			if tt.wantCode == http.StatusForbidden && !strings.Contains(body, `lacks the \"write\" scope`) {
+				t.Errorf("want the missing scope named; got %s", body)
+			}
+		})
+	}
+
+	// Nothing the read-only token tried may have changed the snippet.
+	s, err := app.snippets.Get(id)
+	if err != nil {
+		t.Fatalf("snippet after read-only requests: %v", err)
+	}
+	if s.Content != "An old silent pond" {
+		t.Errorf("want content unchanged; got %q", s.Content)
+	}
+	page, err := app.snippets.List(models.SnippetFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Snippets) != 1 {
+		t.Errorf("want only the one snippet; got %d", len(page.Snippets))
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+// contextKey is an unexported type for the keys we store in a request
+// context, so they can never collide with keys set by other packages.
+type contextKey string
+
+// contextKeyUser holds the *models.User for the logged-in user, added to the
+// request context by the authenticate middleware.
+const contextKeyUser = contextKey("user")
+
+// contextKeyNonce holds the Content-Security-Policy nonce for the request,
+// added by the secureHeaders middleware.
+const contextKeyNonce = contextKey("nonce")
+
+// contextKeyToken holds the *models.Token a request was authenticated with,
+// added by the authenticateToken middleware. It is absent for requests
+// authenticated by the session.
+const contextKeyToken = contextKey("token")
@@ -2,10 +2,18 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
 
+	"github.com/justinas/nosurf"
+	"vincellauderes.net/snippetbox/pkg/diff"
 	"vincellauderes.net/snippetbox/pkg/forms"
+	"vincellauderes.net/snippetbox/pkg/highlight"
 	"vincellauderes.net/snippetbox/pkg/models"
 )
 
@@ -21,10 +29,85 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	tags, err := app.tags.Cloud(30)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	// panic("oops! something went wrong") // Deliberate panic
 
 	app.render(w, r, "home.page.tmpl", &templateData{
 		Snippets: s,
+		TagCloud: newTagCloud(tags),
+	})
+}
+
+// listSnippets shows every unexpired snippet a page at a time. The query
+// string may filter by author and expiry window, choose the sort order and
+// page size, and carries the after/before cursors for paging.
+func (app *application) listSnippets(w http.ResponseWriter, r *http.Request) {
+	f, ok := snippetFilter(forms.New(r.URL.Query()))
+	if !ok {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	page, err := app.snippets.List(f)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "list.page.tmpl", &templateData{
+		Snippets:   page.Snippets,
+		Pagination: newPagination(r.URL, f, page),
+	})
+}
+
+// search shows the snippets matching the q query string parameter, a page at
+// a time.
+func (app *application) search(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	form.MaxLength("q", 200)
+	form.MatchesPattern("page", digitsRX)
+	if !form.Valid() {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	query := strings.TrimSpace(form.Get("q"))
+	if query == "" {
+		app.render(w, r, "search.page.tmpl", nil)
+		return
+	}
+
+	page := 1
+	if p, _ := strconv.Atoi(form.Get("page")); p > 0 {
+		page = p
+	}
+
+	res, err := app.snippets.Search(query, page)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	link := func(page int) string {
+		return "/search?" + url.Values{"q": {query}, "page": {strconv.Itoa(page)}}.Encode()
+	}
+	p := &pagination{}
+	if res.HasPrev() {
+		p.PrevURL = link(page - 1)
+	}
+	if res.HasNext() {
+		p.NextURL = link(page + 1)
+	}
+
+	app.render(w, r, "search.page.tmpl", &templateData{
+		Query:      query,
+		Search:     res,
+		Pagination: p,
 	})
 }
 
@@ -48,6 +131,12 @@ func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	s.Tags, err = app.tags.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	// data := &templateData{Snippet: s}
 
 	// Write the snippet data as a plain-text HTTP response body.
@@ -74,11 +163,6 @@ func (app *application) showSnippet(w http.ResponseWriter, r *http.Request) {
 
 }
 
-func (app *application) users(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	fmt.Fprintf(w, `{"name": "Alex Banks"}`)
-}
-
 func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
 	// First we call r.ParseForm() which adds any data in POST request bodies
 	// to the r.PostForm map. This also works in the same way for PUT and PATCH
@@ -91,9 +175,7 @@ func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
 	}
 
 	form := forms.New(r.PostForm)
-	form.Required("title", "content", "expires")
-	form.MaxLength("title", 100)
-	form.PermittedValues("expires", "365", "7", "1")
+	validateSnippetForm(form)
 
 	if !form.Valid() {
 		app.render(w, r, "create.page.tmpl", &templateData{Form: form})
@@ -106,6 +188,7 @@ func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
 	title := form.Get("title")
 	content := form.Get("content")
 	expires := form.Get("expires")
+	language := snippetLanguage(form)
 
 	// Initialize a map to hold any validation errors.
 	// errors := make(map[string]string)
@@ -140,7 +223,7 @@ func (app *application) createSnippet(w http.ResponseWriter, r *http.Request) {
 	// 	return
 	// }
 
-	id, err := app.snippets.Insert(title, content, expires)
+	id, err := app.snippets.Insert(app.authenticatedUser(r).ID, title, content, language, expires, snippetTags(form))
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -163,6 +246,312 @@ func (app *application) createSnippetForm(w http.ResponseWriter, r *http.Request
 	app.render(w, r, "create.page.tmpl", &templateData{Form: forms.New(nil)})
 }
 
+// keepExpiry is the expires choice on the edit form which leaves the
+// snippet's expiry date as it is.
+const keepExpiry = "keep"
+
+// validateSnippetForm runs the checks shared by every form which creates or
+// changes a snippet. Forms which change one may also pass keepExpiry.
+func validateSnippetForm(form *forms.Form, expires ...string) {
+	form.Required("title", "content", "expires")
+	form.MaxLength("title", 100)
+	form.PermittedValues("expires", append([]string{"365", "7", "1"}, expires...)...)
+	form.MaxItems("tags", 5)
+	form.ItemsMatchPattern("tags", tagRX)
+	form.PermittedValues("language", highlight.Values()...)
+}
+
+// snippetLanguage returns the language chosen on a validated snippet form,
+// falling back to detecting it from the content when none was picked.
+func snippetLanguage(form *forms.Form) string {
+	if language := form.Get("language"); language != "" {
+		return language
+	}
+	return highlight.Detect(form.Get("content"))
+}
+
+// tagRX matches a single tag name. Tags are stored in lowercase but may be
+// typed in any case.
+var tagRX = regexp.MustCompile(`^(?i)[a-z0-9][a-z0-9-]{0,29}$`)
+
+// snippetTags returns the normalised tag names from the tags field of a
+// validated snippet form.
+func snippetTags(form *forms.Form) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, t := range form.List("tags") {
+		t = strings.ToLower(t)
+		if !seen[t] {
+			seen[t] = true
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// lookupSnippet fetches the snippet named by the :id parameter. If there's
+// no such snippet it sends the appropriate error response and returns nil, so
+// the handler should just return.
+func (app *application) lookupSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return nil
+	}
+
+	s, err := app.snippets.Get(id)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return nil
+	} else if err != nil {
+		app.serverError(w, err)
+		return nil
+	}
+
+	return s
+}
+
+// rawSnippet sends a snippet's content as plain text, for use with tools like
+// curl.
+func (app *application) rawSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.lookupSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, s.Content)
+}
+
+// downloadSnippet is like rawSnippet but asks the browser to save the content
+// as a file named after the snippet's title and language.
+func (app *application) downloadSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.lookupSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{
+		"filename": snippetFilename(s),
+	})
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Disposition", disposition)
+	io.WriteString(w, s.Content)
+}
+
+// filenameRX matches the runs of characters replaced with a dash when turning
+// a snippet title into a file name.
+var filenameRX = regexp.MustCompile(`[^a-z0-9]+`)
+
+// snippetFilename returns a file name for a snippet like "an-old-silent-pond.go",
+// falling back to "snippet-<id>" when the title has nothing usable in it.
+func snippetFilename(s *models.Snippet) string {
+	name := filenameRX.ReplaceAllString(strings.ToLower(s.Title), "-")
+	name = strings.Trim(name, "-")
+	if len(name) > 60 {
+		name = strings.TrimRight(name[:60], "-")
+	}
+	if name == "" {
+		name = fmt.Sprintf("snippet-%d", s.ID)
+	}
+	return name + highlight.Ext(s.Language)
+}
+
+// ownSnippet is like lookupSnippet but also checks that the logged-in user
+// wrote the snippet, sending a 403 Forbidden response if not.
+func (app *application) ownSnippet(w http.ResponseWriter, r *http.Request) *models.Snippet {
+	s := app.lookupSnippet(w, r)
+	if s == nil {
+		return nil
+	}
+
+	if s.UserID != app.authenticatedUser(r).ID {
+		app.clientError(w, http.StatusForbidden)
+		return nil
+	}
+
+	return s
+}
+
+func (app *application) editSnippetForm(w http.ResponseWriter, r *http.Request) {
+	s := app.ownSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	tags, err := app.tags.ForSnippet(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	form := forms.New(url.Values{})
+	form.Set("title", s.Title)
+	form.Set("content", s.Content)
+	form.Set("expires", keepExpiry)
+	form.Set("language", s.Language)
+	form.Set("tags", strings.Join(tags, ", "))
+
+	app.render(w, r, "edit.page.tmpl", &templateData{Form: form, Snippet: s})
+}
+
+func (app *application) editSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.ownSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	validateSnippetForm(form, keepExpiry)
+
+	if !form.Valid() {
+		app.render(w, r, "edit.page.tmpl", &templateData{Form: form, Snippet: s})
+		return
+	}
+
+	expires := form.Get("expires")
+	if expires == keepExpiry {
+		expires = ""
+	}
+
+	err = app.snippets.Update(s.ID, app.authenticatedUser(r).ID, form.Get("title"), form.Get("content"), snippetLanguage(form), expires, snippetTags(form))
+	if err == models.ErrNoRecord {
+		// The snippet expired or was deleted since ownSnippet fetched it.
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessions.Put(r, "flash", "Snippet successfully updated!")
+	http.Redirect(w, r, fmt.Sprintf("/snippets/%d", s.ID), http.StatusSeeOther)
+}
+
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	s := app.lookupSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "history.page.tmpl", &templateData{
+		Snippet:   s,
+		Revisions: revisions,
+	})
+}
+
+// snippetDiff shows a unified diff between two revisions of a snippet, given
+// by the from and to query string parameters. When they're missing it
+// compares the latest revision with the one before it.
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	s := app.lookupSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(s.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if len(revisions) == 0 {
+		app.notFound(w)
+		return
+	}
+
+	// Revisions are ordered newest first. Default to comparing the two most
+	// recent ones; a snippet which was never edited is compared with itself.
+	to := revisions[0]
+	from := to
+	if len(revisions) > 1 {
+		from = revisions[1]
+	}
+
+	for param, rev := range map[string]**models.Revision{"from": &from, "to": &to} {
+		v := r.URL.Query().Get(param)
+		if v == "" {
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil || id < 1 {
+			app.notFound(w)
+			return
+		}
+		*rev, err = app.snippets.Revision(s.ID, id)
+		if err == models.ErrNoRecord {
+			app.notFound(w)
+			return
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+	}
+
+	// Revisions too large to compare quickly are only reported as such.
+	hunks, err := diff.Unified(from.Content, to.Content, 3)
+	if err != nil && err != diff.ErrTooLarge {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "diff.page.tmpl", &templateData{
+		Snippet:      s,
+		DiffFrom:     from,
+		DiffTo:       to,
+		Diff:         hunks,
+		DiffTooLarge: err == diff.ErrTooLarge,
+	})
+}
+
+func (app *application) showTag(w http.ResponseWriter, r *http.Request) {
+	name := strings.ToLower(r.URL.Query().Get(":name"))
+	if !tagRX.MatchString(name) {
+		app.notFound(w)
+		return
+	}
+
+	s, err := app.tags.Snippets(name)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "tag.page.tmpl", &templateData{
+		Tag:      name,
+		Snippets: s,
+	})
+}
+
+func (app *application) deleteSnippet(w http.ResponseWriter, r *http.Request) {
+	s := app.ownSnippet(w, r)
+	if s == nil {
+		return
+	}
+
+	err := app.snippets.Delete(s.ID)
+	if err != nil && err != models.ErrNoRecord {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessions.Put(r, "flash", "Snippet successfully deleted!")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
 func (app *application) signupUserForm(w http.ResponseWriter, r *http.Request) {
 	app.render(w, r, "signup.page.tmpl", &templateData{
 		Form: forms.New(nil),
@@ -180,6 +569,7 @@ func (app *application) signupUser(w http.ResponseWriter, r *http.Request) {
 	form.Required("name", "email", "password")
 	form.MatchesPattern("email", forms.EmailRX)
 	form.MinLength("password", 10)
+	form.MaxBytes("password", models.MaxPasswordBytes)
 
 	if !form.Valid() {
 		app.render(w, r, "signup.page.tmpl", &templateData{Form: form})
@@ -214,6 +604,30 @@ func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
 	}
 
 	form := forms.New(r.PostForm)
+
+	// Refuse to even check the password while the email address or client
+	// is throttled, so guessing is slow however much CPU is thrown at it.
+	// The message is the same whichever of them is blocked. Allow counts
+	// the attempt as failed until the password turns out to be right.
+	emailKey := strings.ToLower(strings.TrimSpace(form.Get("email")))
+	ipKey := app.clientIP(r)
+	okEmail, waitEmail := app.loginEmails.Allow(emailKey)
+	okIP, waitIP := app.loginIPs.Allow(ipKey)
+	if !okEmail || !okIP {
+		// The password isn't checked, so this isn't an attempt.
+		if okEmail {
+			app.loginEmails.Refund(emailKey)
+		}
+		if okIP {
+			app.loginIPs.Refund(ipKey)
+		}
+		wait := max(waitEmail, waitIP)
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		form.Errors.Add("generic", fmt.Sprintf("Too many failed login attempts. Please try again in %s.", humanDuration(wait)))
+		app.renderStatus(w, r, http.StatusTooManyRequests, "login.page.tmpl", &templateData{Form: form})
+		return
+	}
+
 	id, err := app.userss.Authenticate(form.Get("email"), form.Get("password"))
 	if err == models.ErrInvalidCredentials {
 		form.Errors.Add("generic", "Email or Password is incorrect")
@@ -224,9 +638,12 @@ func (app *application) loginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	app.sessions.Put(r, "userID", id)
-
-	http.Redirect(w, r, "/snippets/create", http.StatusSeeOther)
+	// Only the email address is forgiven on success. Forgiving the IP
+	// address would let an attacker with one account of their own reset
+	// their allowance at will, so it just gets this attempt back.
+	app.loginEmails.Reset(emailKey)
+	app.loginIPs.Refund(ipKey)
+	app.startLogin(w, r, id)
 }
 
 func (app *application) logoutUser(w http.ResponseWriter, r *http.Request) {
@@ -235,3 +652,99 @@ func (app *application) logoutUser(w http.ResponseWriter, r *http.Request) {
 	app.sessions.Put(r, "flash", "You've been logged out successfully!")
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
+
+func (app *application) userProfile(w http.ResponseWriter, r *http.Request) {
+	app.renderProfile(w, r, &templateData{
+		Form: forms.New(nil),
+		// A newly created API token is passed along from createToken
+		// through the session, since this is the only time it's shown.
+		Token: app.sessions.PopString(r, "token"),
+	})
+}
+
+// renderProfile renders the profile page with the logged-in user's snippets
+// and API tokens added to td.
+func (app *application) renderProfile(w http.ResponseWriter, r *http.Request, td *templateData) {
+	// The requireAuthenticatedUser middleware guarantees there's a user in
+	// the request context, and addDefaultData exposes it to the template.
+	userID := app.authenticatedUser(r).ID
+
+	var err error
+	td.Snippets, err = app.snippets.ByUser(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	td.Tokens, err = app.tokens.ForUser(userID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "profile.page.tmpl", td)
+}
+
+// createToken mints an API token for the logged-in user and shows it once on
+// their profile page.
+func (app *application) createToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("name", "scope")
+	form.MaxLength("name", 100)
+	form.PermittedValues("scope", models.ScopeRead, models.ScopeWrite)
+
+	if !form.Valid() {
+		app.renderProfile(w, r, &templateData{Form: form})
+		return
+	}
+
+	token, err := app.tokens.Insert(app.authenticatedUser(r).ID, form.Get("name"), form.Get("scope"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessions.Put(r, "flash", "Your API token has been created. Copy it now, it won't be shown again.")
+	app.sessions.Put(r, "token", token)
+	http.Redirect(w, r, "/user/profile", http.StatusSeeOther)
+}
+
+// deleteToken revokes one of the logged-in user's API tokens.
+func (app *application) deleteToken(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get(":id"))
+	if err != nil || id < 1 {
+		app.notFound(w)
+		return
+	}
+
+	err = app.tokens.Delete(id, app.authenticatedUser(r).ID)
+	if err == models.ErrNoRecord {
+		app.notFound(w)
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessions.Put(r, "flash", "API token revoked.")
+	http.Redirect(w, r, "/user/profile", http.StatusSeeOther)
+}
+
+// csrfFailure tells the user their form submission was rejected because its
+// CSRF token didn't match. API requests get the JSON error envelope.
+func (app *application) csrfFailure(w http.ResponseWriter, r *http.Request) {
+	app.infoLog.Printf("CSRF check failed for %s %s: %v", r.Method, r.URL, nosurf.Reason(r))
+
+	if strings.HasPrefix(r.URL.Path, "/api/") {
+		app.apiError(w, http.StatusBadRequest, "The CSRF token is missing or invalid. Send it in the X-CSRF-Token header.")
+		return
+	}
+
+	app.renderStatus(w, r, http.StatusBadRequest, "csrf.page.tmpl", nil)
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"vincellauderes.net/snippetbox/pkg/diff"
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+func TestShowSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	id, err := app.snippets.Insert(0, "An old silent pond", "An old silent pond...", "", "7", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{"Valid ID", "/snippets/" + strconv.Itoa(id), http.StatusOK, "An old silent pond..."},
+		{"Non-existent ID", "/snippets/2", http.StatusNotFound, ""},
+		{"Negative ID", "/snippets/-1", http.StatusNotFound, ""},
+		{"Decimal ID", "/snippets/1.23", http.StatusNotFound, ""},
+		{"String ID", "/snippets/foo", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, body := ts.get(t, tt.urlPath)
+			if rs.StatusCode != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, rs.StatusCode)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSignupAndLogin(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	rs, _ := ts.get(t, "/user/profile")
+	if rs.StatusCode != http.StatusFound || rs.Header.Get("Location") != "/user/login" {
+		t.Fatalf("anonymous profile: got %d to %q", rs.StatusCode, rs.Header.Get("Location"))
+	}
+
+	rs, _ = ts.postForm(t, "/user/signup", url.Values{
+		"name":     {"Alice"},
+		"email":    {"alice@example.com"},
+		"password": {"password123"},
+	})
+	if rs.StatusCode != http.StatusSeeOther {
+		t.Fatalf("signup: want %d; got %d", http.StatusSeeOther, rs.StatusCode)
+	}
+
+	rs, body := ts.postForm(t, "/user/login", url.Values{"email": {"alice@example.com"}, "password": {"wrong-password"}})
+	if rs.StatusCode != http.StatusOK || !strings.Contains(body, "Email or Password is incorrect") {
+		t.Fatalf("wrong password: got %d", rs.StatusCode)
+	}
+
+	rs, _ = ts.postForm(t, "/user/login", url.Values{"email": {"alice@example.com"}, "password": {"password123"}})
+	if rs.StatusCode != http.StatusSeeOther {
+		t.Fatalf("login: want %d; got %d", http.StatusSeeOther, rs.StatusCode)
+	}
+
+	rs, _ = ts.get(t, "/snippets/create")
+	if rs.StatusCode != http.StatusOK {
+		t.Fatalf("create form: want %d; got %d", http.StatusOK, rs.StatusCode)
+	}
+
+	rs, body = ts.get(t, "/user/profile")
+	if rs.StatusCode != http.StatusOK || !strings.Contains(body, "alice@example.com") {
+		t.Fatalf("profile: got %d", rs.StatusCode)
+	}
+}
+
+func TestEditSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	authorID := ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+	id, err := app.snippets.Insert(authorID, "Haiku", "An old silent pond", "", "1", []string{"haiku"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before, err := app.snippets.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/snippets/" + strconv.Itoa(id)
+
+	rs, body := ts.get(t, path+"/edit")
+	if rs.StatusCode != http.StatusOK {
+		t.Fatalf("edit form: want %d; got %d", http.StatusOK, rs.StatusCode)
+	}
+	if !strings.Contains(body, "value='keep' checked") {
+		t.Error("edit form doesn't keep the current expiry by default")
+	}
+	if !strings.Contains(body, "value='haiku'") {
+		t.Error("edit form doesn't show the current tags")
+	}
+
+	form := url.Values{"title": {"Haiku"}, "content": {"A frog jumps into the pond"}, "expires": {"keep"}, "tags": {"Pond, frog, pond"}}
+	rs, _ = ts.postForm(t, path+"/edit", form)
+	if rs.StatusCode != http.StatusSeeOther {
+		t.Fatalf("edit: want %d; got %d", http.StatusSeeOther, rs.StatusCode)
+	}
+
+	after, err := app.snippets.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Content != "A frog jumps into the pond" {
+		t.Errorf("content not updated: %q", after.Content)
+	}
+	if !after.Expires.Equal(before.Expires) {
+		t.Errorf("expiry changed from %v to %v", before.Expires, after.Expires)
+	}
+	tags, err := app.tags.ForSnippet(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(tags, ",") != "frog,pond" {
+		t.Errorf("want tags frog,pond; got %q", tags)
+	}
+
+	// Someone else may neither edit nor delete the snippet.
+	other := newTestServer(t, app.routes())
+	other.signupAndLogin(t, app, "Bob", "bob@example.com")
+
+	rs, _ = other.get(t, path+"/edit")
+	if rs.StatusCode != http.StatusForbidden {
+		t.Errorf("edit form by another user: want %d; got %d", http.StatusForbidden, rs.StatusCode)
+	}
+	rs, _ = other.postForm(t, path+"/edit", form)
+	if rs.StatusCode != http.StatusForbidden {
+		t.Errorf("edit by another user: want %d; got %d", http.StatusForbidden, rs.StatusCode)
+	}
+	rs, _ = other.postForm(t, path+"/delete", url.Values{})
+	if rs.StatusCode != http.StatusForbidden {
+		t.Errorf("delete by another user: want %d; got %d", http.StatusForbidden, rs.StatusCode)
+	}
+	if _, err := app.snippets.Get(id); err != nil {
+		t.Errorf("snippet gone after forbidden delete: %v", err)
+	}
+}
+
+func TestSnippetDiffTooLarge(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	content := strings.Repeat("line\n", diff.MaxLines)
+	id, err := app.snippets.Insert(0, "Big", content, "", "7", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.snippets.Update(id, 0, "Big", content+"one more\n", "", "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	rs, body := ts.get(t, "/snippets/"+strconv.Itoa(id)+"/diff")
+	if rs.StatusCode != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, rs.StatusCode)
+	}
+	if !strings.Contains(body, "too large to diff") {
+		t.Error("large revisions weren't refused")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	_, err := app.snippets.Insert(0, "Haiku", "An old <b>silent</b> pond", "", "7", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		wantCode int
+		wantBody string
+	}{
+		{"Empty query", "", http.StatusOK, "Search titles and content"},
+		{"Blank query", "   ", http.StatusOK, "Search titles and content"},
+		{"Match", "pond", http.StatusOK, "1 snippet matches"},
+		{"Operators", `"pond"* -(haiku)`, http.StatusOK, "1 snippet matches"},
+		{"No match", "frog", http.StatusOK, "0 snippets match"},
+		{"Escaped content", "pond", http.StatusOK, "An old &lt;b&gt;silent&lt;/b&gt; <mark>pond</mark>"},
+		{"Escaped query", "<b>", http.StatusOK, "<strong>&lt;b&gt;</strong>"},
+		{"Too long", strings.Repeat("a", 201), http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, body := ts.get(t, "/search?"+url.Values{"q": {tt.query}}.Encode())
+			if rs.StatusCode != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, rs.StatusCode)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+			if strings.Contains(body, "<b>") {
+				t.Error("unescaped HTML in the results")
+			}
+		})
+	}
+}
+
+func TestRawAndDownloadSnippet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	content := "<script>alert(1)</script>\npackage main\n"
+	id, err := app.snippets.Insert(0, `Say "hi"`+"\r\nContent-Type: text/html", content, "go", "7", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := app.snippets.Insert(0, "Gone", "gone", "", "-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kind := range []string{"raw", "download"} {
+		t.Run(kind, func(t *testing.T) {
+			rs, body := ts.get(t, "/snippets/"+strconv.Itoa(id)+"/"+kind)
+			if rs.StatusCode != http.StatusOK {
+				t.Fatalf("want %d; got %d", http.StatusOK, rs.StatusCode)
+			}
+			if got := rs.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+				t.Errorf("Content-Type: got %q", got)
+			}
+			if got := rs.Header.Get("X-Content-Type-Options"); got != "nosniff" {
+				t.Errorf("X-Content-Type-Options: got %q", got)
+			}
+			if body != content {
+				t.Errorf("want body %q; got %q", content, body)
+			}
+
+			disposition := rs.Header.Get("Content-Disposition")
+			if kind == "raw" {
+				if disposition != "" {
+					t.Errorf("want no Content-Disposition; got %q", disposition)
+				}
+			} else {
+				mediaType, params, err := mime.ParseMediaType(disposition)
+				if err != nil {
+					t.Fatalf("Content-Disposition %q: %v", disposition, err)
+				}
+				if mediaType != "attachment" || params["filename"] != "say-hi-content-type-text-html.go" {
+					t.Errorf("Content-Disposition: got %q", disposition)
+				}
+			}
+
+			rs, _ = ts.get(t, "/snippets/"+strconv.Itoa(expired)+"/"+kind)
+			if rs.StatusCode != http.StatusNotFound {
+				t.Errorf("expired: want %d; got %d", http.StatusNotFound, rs.StatusCode)
+			}
+			rs, _ = ts.get(t, "/snippets/99/"+kind)
+			if rs.StatusCode != http.StatusNotFound {
+				t.Errorf("missing: want %d; got %d", http.StatusNotFound, rs.StatusCode)
+			}
+		})
+	}
+}
+
+func TestSnippetFilename(t *testing.T) {
+	tests := []struct {
+		name     string
+		title    string
+		language string
+		want     string
+	}{
+		{"Title and language", "An old silent pond", "go", "an-old-silent-pond.go"},
+		{"Plain text", "Notes", "", "notes.txt"},
+		{"Unknown language", "Notes", "cobol", "notes.txt"},
+		{"Quotes", `Say "hi"; rm -rf /`, "bash", "say-hi-rm-rf.sh"},
+		{"Newlines", "Line one\r\nLine two\n", "python", "line-one-line-two.py"},
+		{"Nothing usable", `"日本語"`, "", "snippet-7.txt"},
+		{"Long title", strings.Repeat("abcde ", 20), "", strings.TrimSuffix(strings.Repeat("abcde-", 10), "-") + ".txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := snippetFilename(&models.Snippet{ID: 7, Title: tt.title, Language: tt.language})
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCSRFRejection(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+
+	tests := []struct {
+		name      string
+		csrfToken []string
+		wantCode  int
+	}{
+		{"Missing token", []string{""}, http.StatusBadRequest},
+		{"Wrong token", []string{"wFjwZ4MylTwAjNWMONt6cbnAyqdvjEqcjRl8TrrDRNXXCFQRrEwRydpeu2lHMOh1SyS3rVgmJ6RmWtJyhJD1Ww=="}, http.StatusBadRequest},
+		{"Valid token", nil, http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{"title": {"Hello"}, "content": {"World"}, "expires": {"7"}}
+			if tt.csrfToken != nil {
+				form["csrf_token"] = tt.csrfToken
+			}
+			rs, _ := ts.postForm(t, "/snippets/create", form)
+			if rs.StatusCode != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, rs.StatusCode)
+			}
+		})
+	}
+
+	// Scripts using the session instead of an API token have to send the
+	// token in a header, and get a JSON error without it.
+	body := `{"title": "Hello", "content": "World", "expires": 7}`
+	header := http.Header{"Content-Type": {"application/json"}}
+
+	t.Run("API without header", func(t *testing.T) {
+		rs, body := ts.do(t, http.MethodPost, "/api/v1/snippets", strings.NewReader(body), header)
+		if rs.StatusCode != http.StatusBadRequest || !strings.Contains(body, `"status": 400`) {
+			t.Errorf("want a %d error envelope; got %d: %s", http.StatusBadRequest, rs.StatusCode, body)
+		}
+	})
+
+	t.Run("API with header", func(t *testing.T) {
+		header := http.Header{"Content-Type": {"application/json"}, "X-CSRF-Token": {ts.csrfToken(t)}}
+		rs, body := ts.do(t, http.MethodPost, "/api/v1/snippets", strings.NewReader(body), header)
+		if rs.StatusCode != http.StatusCreated {
+			t.Errorf("want %d; got %d: %s", http.StatusCreated, rs.StatusCode, body)
+		}
+	})
+}
+
+// authCounter passes Authenticate through to the wrapped store, counting the
+// passwords it checks.
+type authCounter struct {
+	models.UserStore
+	calls atomic.Int32
+}
+
+func (a *authCounter) Authenticate(email, password string) (int, error) {
+	a.calls.Add(1)
+	return a.UserStore.Authenticate(email, password)
+}
+
+// TestLoginThrottleParallel checks that wrong passwords sent all at once are
+// throttled like ones sent one after the other.
+func TestLoginThrottleParallel(t *testing.T) {
+	app := newTestApplication(t)
+	counter := &authCounter{UserStore: app.userss}
+	app.userss = counter
+	ts := newTestServer(t, app.routes())
+
+	if err := app.userss.Insert("Alice", "alice@example.com", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	token := ts.csrfToken(t)
+
+	var limited atomic.Int32
+	t.Run("Guesses", func(t *testing.T) {
+		for i := 0; i < 30; i++ {
+			i := i
+			t.Run(strconv.Itoa(i), func(t *testing.T) {
+				t.Parallel()
+				rs, _ := ts.postForm(t, "/user/login", url.Values{
+					"email":      {"alice@example.com"},
+					"password":   {"wrong-password-" + strconv.Itoa(i)},
+					"csrf_token": {token},
+				})
+				if rs.StatusCode == http.StatusTooManyRequests {
+					limited.Add(1)
+				}
+			})
+		}
+	})
+
+	if want := int32(loginEmailPolicy.Free + 1); counter.calls.Load() != want {
+		t.Errorf("%d passwords checked; want %d", counter.calls.Load(), want)
+	}
+	if limited.Load() != 30-counter.calls.Load() {
+		t.Errorf("%d guesses throttled; want the other %d", limited.Load(), 30-counter.calls.Load())
+	}
+}
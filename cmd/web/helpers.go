@@ -3,9 +3,18 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
+	"regexp"
 	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/justinas/nosurf"
+	"vincellauderes.net/snippetbox/pkg/forms"
+	"vincellauderes.net/snippetbox/pkg/models"
 )
 
 // Te serverError helper writes an error message and stack trace to the errorLog
@@ -30,6 +39,12 @@ func (app *application) notFound(w http.ResponseWriter) {
 }
 
 func (app *application) render(w http.ResponseWriter, r *http.Request, name string, td *templateData) {
+	app.renderStatus(w, r, http.StatusOK, name, td)
+}
+
+// renderStatus is like render but responds with the given status code, for
+// pages such as errors.
+func (app *application) renderStatus(w http.ResponseWriter, r *http.Request, status int, name string, td *templateData) {
 	// Retrieve the appropriate template set from the cache based on the page n
 	// (like 'home.page.tmpl'). If no entry exists in the cache with the
 	// provided name, call the serverError helper method that we made earlier.
@@ -55,6 +70,7 @@ func (app *application) render(w http.ResponseWriter, r *http.Request, name stri
 	// Write the contents to the buffer to the http.ResponseWriter. Again, this
 	// is another time where we pass our http.ResponseWriter to a function that
 	// takes an io.Writer.
+	w.WriteHeader(status)
 	buf.WriteTo(w)
 }
 
@@ -70,9 +86,169 @@ func (app *application) addDefaultData(td *templateData, r *http.Request) *templ
 	td.CurrentYear = time.Now().Year()
 	// Add the flash message to the template data, if one exists.
 	td.Flash = app.sessions.PopString(r, "flash")
+	// Add the CSRF token which every form has to send back.
+	td.CSRFToken = nosurf.Token(r)
+	td.CSPNonce, _ = r.Context().Value(contextKeyNonce).(string)
 	return td
 }
 
-func (app *application) authenticatedUser(r *http.Request) int {
-	return app.sessions.GetInt(r, "userID")
+// clientIP returns the IP address a request came from. That's the address of
+// the connection, unless it comes from one of the trusted proxies. Then the
+// X-Forwarded-For header is read from the right, skipping further trusted
+// proxies, since only the entries they added can be believed.
+func (app *application) clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	if !app.trustedProxy(ip) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !app.trustedProxy(ip) {
+			break
+		}
+	}
+	return ip
+}
+
+// trustedProxy reports whether ip belongs to one of the trusted proxies.
+func (app *application) trustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, n := range app.trustedProxies {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// humanDuration describes a wait like "20 seconds", "15 minutes" or "1 hour",
+// rounding up. Whole hours are given in hours.
+func humanDuration(d time.Duration) string {
+	if d <= time.Minute {
+		s := int((d + time.Second - 1) / time.Second)
+		if s == 1 {
+			return "1 second"
+		}
+		return fmt.Sprintf("%d seconds", s)
+	}
+	m := int((d + time.Minute - 1) / time.Minute)
+	if m%60 == 0 {
+		if h := m / 60; h > 1 {
+			return fmt.Sprintf("%d hours", h)
+		}
+		return "1 hour"
+	}
+	return fmt.Sprintf("%d minutes", m)
+}
+
+// authenticatedUser returns the logged-in user placed in the request context
+// by the authenticate middleware, or nil for anonymous requests.
+func (app *application) authenticatedUser(r *http.Request) *models.User {
+	user, ok := r.Context().Value(contextKeyUser).(*models.User)
+	if !ok {
+		return nil
+	}
+	return user
+}
+
+const (
+	// defaultPageSize and maxPageSize bound how many snippets a listing
+	// shows per page.
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// digitsRX matches positive integers in query string parameters.
+var digitsRX = regexp.MustCompile(`^[1-9][0-9]*$`)
+
+// snippetFilter reads the sort, expires, author, limit and after or before
+// query string parameters of a listing into a filter. It returns false if
+// any of them are invalid, having recorded the reason in the form's errors.
+func snippetFilter(form *forms.Form) (models.SnippetFilter, bool) {
+	form.PermittedValues("sort", "newest", "oldest")
+	form.PermittedValues("expires", "1", "7", "30")
+	form.MatchesPattern("author", digitsRX)
+	form.MatchesPattern("limit", digitsRX)
+
+	f := models.SnippetFilter{
+		Oldest: form.Get("sort") == "oldest",
+		Limit:  defaultPageSize,
+	}
+	f.AuthorID, _ = strconv.Atoi(form.Get("author"))
+	f.ExpiresWithin, _ = strconv.Atoi(form.Get("expires"))
+	if limit, _ := strconv.Atoi(form.Get("limit")); limit > 0 {
+		f.Limit = min(limit, maxPageSize)
+	}
+
+	var err error
+	if after := form.Get("after"); after != "" {
+		f.After, err = models.ParseCursor(after)
+		if err != nil {
+			form.Errors.Add("after", "This field is invalid")
+		}
+	} else if before := form.Get("before"); before != "" {
+		f.Before, err = models.ParseCursor(before)
+		if err != nil {
+			form.Errors.Add("before", "This field is invalid")
+		}
+	}
+
+	return f, form.Valid()
+}
+
+// newPagination builds the links to the pages either side of a listing page.
+// They keep the current query string and swap the cursor parameters.
+func newPagination(u *url.URL, f models.SnippetFilter, page *models.SnippetPage) *pagination {
+	p := &pagination{
+		Limit:    f.Limit,
+		Sort:     "newest",
+		AuthorID: f.AuthorID,
+		Expires:  u.Query().Get("expires"),
+	}
+	if f.Oldest {
+		p.Sort = "oldest"
+	}
+
+	link := func(param string, c *models.Cursor) string {
+		q := u.Query()
+		q.Del("after")
+		q.Del("before")
+		q.Set("limit", strconv.Itoa(f.Limit))
+		q.Set(param, c.String())
+		return u.Path + "?" + q.Encode()
+	}
+	if page.Next != nil {
+		p.NextURL = link("after", page.Next)
+	}
+	if page.Prev != nil {
+		p.PrevURL = link("before", page.Prev)
+	}
+
+	return p
+}
+
+// newTagCloud sizes tags relative to the most used one for the tag cloud.
+func newTagCloud(tags []*models.Tag) []cloudTag {
+	most := 0
+	for _, t := range tags {
+		most = max(most, t.Count)
+	}
+
+	cloud := make([]cloudTag, len(tags))
+	for i, t := range tags {
+		cloud[i] = cloudTag{Name: t.Name, Count: t.Count, Size: 1 + 4*t.Count/most}
+	}
+	return cloud
 }
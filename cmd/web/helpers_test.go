@@ -0,0 +1,73 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseTrustedProxies("10.0.0.0/8, 192.168.1.1, ::1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := &application{trustedProxies: trusted}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"Direct", "203.0.113.5:1234", nil, "203.0.113.5"},
+		{"Untrusted sender's header ignored", "203.0.113.5:1234", []string{"1.2.3.4"}, "203.0.113.5"},
+		{"Trusted proxy without header", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"Trusted proxy", "10.0.0.1:1234", []string{"1.2.3.4"}, "1.2.3.4"},
+		{"Spoofed entries on the left", "10.0.0.1:1234", []string{"6.6.6.6, 7.7.7.7, 1.2.3.4"}, "1.2.3.4"},
+		{"Chain of trusted proxies", "10.0.0.1:1234", []string{"6.6.6.6, 1.2.3.4, 10.0.0.2, 192.168.1.1"}, "1.2.3.4"},
+		{"Only trusted proxies", "10.0.0.1:1234", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"Malformed entry stops the walk", "10.0.0.1:1234", []string{"1.2.3.4, garbage"}, "10.0.0.1"},
+		{"Malformed entry past the client", "10.0.0.1:1234", []string{"garbage, 1.2.3.4"}, "1.2.3.4"},
+		{"Several header lines", "10.0.0.1:1234", []string{"6.6.6.6", "1.2.3.4"}, "1.2.3.4"},
+		{"IPv6", "[::1]:1234", []string{"2001:db8::1"}, "2001:db8::1"},
+		{"No port", "10.0.0.1", []string{"1.2.3.4"}, "1.2.3.4"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+
+			if got := app.clientIP(r); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHumanDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{time.Millisecond, "1 second"},
+		{time.Second, "1 second"},
+		{1500 * time.Millisecond, "2 seconds"},
+		{time.Minute, "60 seconds"},
+		{time.Minute + time.Second, "2 minutes"},
+		{15 * time.Minute, "15 minutes"},
+		{59*time.Minute + time.Second, "1 hour"},
+		{time.Hour, "1 hour"},
+		{90 * time.Minute, "90 minutes"},
+		{2 * time.Hour, "2 hours"},
+	}
+
+	for _, tt := range tests {
+		if got := humanDuration(tt.d); got != tt.want {
+			t.Errorf("humanDuration(%s) = %q; want %q", tt.d, got, tt.want)
+		}
+	}
+}
@@ -1,20 +1,65 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/justinas/nosurf"
+
+	"vincellauderes.net/snippetbox/pkg/models"
+	"vincellauderes.net/snippetbox/pkg/ratelimit"
 )
 
 // my middleware -> servemux -> application handler
 
 // Alternatively you can position the middleware after the servemux in the chain
 // by wrapping a specific application handler
-func secureHeaders(next http.Handler) http.Handler {
+//
+// secureHeaders sets the headers of app.security on every response. It also
+// generates the Content-Security-Policy nonce and adds it to the request
+// context for the templates.
+func (app *application) secureHeaders(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("X-XSS-Protection", "1; mode=block")
+		p := app.security
+
+		nonce, err := newNonce()
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		if p.ContentSecurityPolicy != "" {
+			header := "Content-Security-Policy"
+			if p.CSPReportOnly {
+				header = "Content-Security-Policy-Report-Only"
+			}
+			w.Header().Set(header, strings.ReplaceAll(p.ContentSecurityPolicy, "{nonce}", nonce))
+		}
+		if p.StrictTransportSecurity != "" {
+			w.Header().Set("Strict-Transport-Security", p.StrictTransportSecurity)
+		}
+		if p.ReferrerPolicy != "" {
+			w.Header().Set("Referrer-Policy", p.ReferrerPolicy)
+		}
+		if p.PermissionsPolicy != "" {
+			w.Header().Set("Permissions-Policy", p.PermissionsPolicy)
+		}
+		if p.CrossOriginOpenerPolicy != "" {
+			w.Header().Set("Cross-Origin-Opener-Policy", p.CrossOriginOpenerPolicy)
+		}
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		// frame-ancestors supersedes X-Frame-Options, but older browsers
+		// only understand the latter. X-XSS-Protection is switched off, as
+		// the filter it controlled could itself be abused.
 		w.Header().Set("X-Frame-Options", "deny")
+		w.Header().Set("X-XSS-Protection", "0")
 
-		next.ServeHTTP(w, r)
+		ctx := context.WithValue(r.Context(), contextKeyNonce, nonce)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
@@ -47,7 +92,7 @@ func (app *application) recoverPanic(next http.Handler) http.Handler {
 
 func (app *application) requireAuthenticatedUser(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if app.authenticatedUser(r) == 0 {
+		if app.authenticatedUser(r) == nil {
 			http.Redirect(w, r, "/user/login", http.StatusFound)
 			return
 		}
@@ -55,3 +100,167 @@ func (app *application) requireAuthenticatedUser(next http.Handler) http.Handler
 		next.ServeHTTP(w, r)
 	})
 }
+
+// authenticate looks up the user whose ID is stored in the session and adds
+// their details to the request context. If the user no longer exists their
+// stale ID is removed from the session and the request carries on anonymous.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.sessions.Exists(r, "userID") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		user, err := app.userss.Get(app.sessions.GetInt(r, "userID"))
+		if err == models.ErrNoRecord {
+			app.sessions.Remove(r, "userID")
+			next.ServeHTTP(w, r)
+			return
+		} else if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyUser, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// authenticateToken authenticates requests carrying an "Authorization:
+// Bearer" API token, adding the token's owner to the request context just
+// like authenticate does for the session. Requests without the header carry
+// on as they are, while ones with an invalid token are rejected.
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		invalid := func() {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="snippetbox", error="invalid_token"`)
+			app.apiError(w, http.StatusUnauthorized, "Invalid or revoked API token")
+		}
+
+		plain, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok {
+			invalid()
+			return
+		}
+
+		token, err := app.tokens.Authenticate(strings.TrimSpace(plain))
+		if err == models.ErrInvalidCredentials {
+			invalid()
+			return
+		} else if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+
+		user, err := app.userss.Get(token.UserID)
+		if err == models.ErrNoRecord {
+			invalid()
+			return
+		} else if err != nil {
+			app.apiServerError(w, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), contextKeyUser, user)
+		ctx = context.WithValue(ctx, contextKeyToken, token)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requireScope rejects requests authenticated with an API token which doesn't
+// grant scope. Requests authenticated by the session have every scope.
+func (app *application) requireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := r.Context().Value(contextKeyToken).(*models.Token)
+			if ok && !token.Allows(scope) {
+				app.apiError(w, http.StatusForbidden, fmt.Sprintf("This API token lacks the %q scope", scope))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// noSurf protects every form submission from cross-site request forgery. It
+// sets a random token in a cookie which forms have to echo in a csrf_token
+// field, or scripts in an X-CSRF-Token header, for any request other than
+// GET, HEAD, OPTIONS or TRACE. Requests carrying an API token are exempt, as
+// browsers never add those by themselves.
+func (app *application) noSurf(next http.Handler) http.Handler {
+	csrfHandler := nosurf.New(next)
+	csrfHandler.SetBaseCookie(http.Cookie{
+		HttpOnly: true,
+		Path:     "/",
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	csrfHandler.ExemptFunc(func(r *http.Request) bool {
+		return strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ")
+	})
+	csrfHandler.SetFailureHandler(http.HandlerFunc(app.csrfFailure))
+
+	return csrfHandler
+}
+
+// rateLimit returns middleware limiting each client to the rate allowed by
+// limiter, where key names the client a request comes from. Responses carry
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers, and
+// requests over the limit get 429 Too Many Requests with Retry-After. It does
+// nothing when rate limiting is turned off with -rate-limit=false.
+func (app *application) rateLimit(limiter *ratelimit.Limiter, key func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if !app.limitRequests {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res := limiter.Take(key(r))
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+			if !res.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				if strings.HasPrefix(r.URL.Path, "/api/") {
+					app.apiError(w, http.StatusTooManyRequests, "Too many requests, please slow down")
+					return
+				}
+				app.clientError(w, http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ipKey identifies the client of a request by IP address, for rate limits.
+func (app *application) ipKey(r *http.Request) string {
+	return "ip:" + app.clientIP(r)
+}
+
+// userKey identifies the client of a request by the authenticated user, or
+// by IP address for anonymous requests, for rate limits. It must run after
+// authenticate and authenticateToken.
+func (app *application) userKey(r *http.Request) string {
+	if user := app.authenticatedUser(r); user != nil {
+		return "user:" + strconv.Itoa(user.ID)
+	}
+	return app.ipKey(r)
+}
+
+// ceilSeconds rounds d up to whole seconds.
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// TestWriteLimit checks that routes which change a user's account count
+// against the per-user write limit, like the ones which change snippets.
+func TestWriteLimit(t *testing.T) {
+	for _, path := range []string{"/user/tokens/99/delete", "/user/2fa/enable", "/user/2fa/disable"} {
+		t.Run(path, func(t *testing.T) {
+			app := newTestApplication(t)
+			app.limitRequests = true
+			ts := newTestServer(t, app.routes())
+			ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+
+			token := ts.csrfToken(t)
+			for i := 1; i <= 11; i++ {
+				rs, _ := ts.postForm(t, path, url.Values{"csrf_token": {token}})
+				if got := rs.Header.Get("RateLimit-Limit"); got != "10" {
+					t.Fatalf("request %d: want the write limit's headers; got RateLimit-Limit %q", i, got)
+				}
+
+				limited := rs.StatusCode == http.StatusTooManyRequests
+				if limited != (i == 11) {
+					t.Fatalf("request %d: got %d", i, rs.StatusCode)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"vincellauderes.net/snippetbox/pkg/migrations"
+)
+
+// runMigrate implements the "migrate up|down|status" subcommand, which
+// manages the database schema without starting the web server.
+func runMigrate(m *migrations.Migrator, args []string, infoLog *log.Logger) error {
+	if len(args) != 1 {
+		return errors.New("usage: web [flags] migrate up|down|status")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := m.Up()
+		for _, mg := range applied {
+			infoLog.Printf("Applied migration %04d_%s", mg.Version, mg.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			infoLog.Println("No pending migrations")
+		}
+	case "down":
+		mg, err := m.Down()
+		if err != nil {
+			return err
+		}
+		if mg == nil {
+			infoLog.Println("No migrations to roll back")
+			return nil
+		}
+		infoLog.Printf("Rolled back migration %04d_%s", mg.Version, mg.Name)
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied != nil {
+				applied = s.Applied.UTC().Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%-30s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q", args[0])
+	}
+
+	return nil
+}
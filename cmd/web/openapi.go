@@ -0,0 +1,423 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// The OpenAPI document served at /api/openapi.json is built from the same
+// calls that register the API routes: apiRouter.handle takes the operation's
+// description along with its handler, and refuses to register a route which
+// isn't described. That way the document can't fall out of step with
+// routes.go.
+
+// openAPIDoc is an OpenAPI 3 document, trimmed to the parts this application
+// uses.
+type openAPIDoc struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       openAPIInfo                      `json:"info"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components map[string]any                   `json:"components"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// operation describes one method on one path.
+type operation struct {
+	Summary     string                `json:"summary"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+	Schema      schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema schema `json:"schema"`
+}
+
+// schema is a JSON Schema object.
+type schema map[string]any
+
+// ref points at one of the schemas in the document's components.
+func ref(name string) schema {
+	return schema{"$ref": "#/components/schemas/" + name}
+}
+
+// jsonBody and jsonResponse wrap a schema as application/json content.
+func jsonBody(s schema) *requestBody {
+	return &requestBody{Required: true, Content: map[string]mediaType{"application/json": {s}}}
+}
+
+func jsonResponse(description string, s schema) response {
+	return response{description, map[string]mediaType{"application/json": {s}}}
+}
+
+func textResponse(description string) response {
+	return response{description, map[string]mediaType{"text/plain": {schema{"type": "string"}}}}
+}
+
+// The error responses shared by many operations. They all use the error
+// envelope written by apiError and apiValidationError.
+var (
+	badRequestResponse   = jsonResponse("The request body is malformed.", ref("Error"))
+	unauthorizedResponse = jsonResponse("No valid session or API token was sent.", ref("Error"))
+	invalidTokenResponse = jsonResponse("An API token was sent but is invalid or revoked.", ref("Error"))
+	forbiddenResponse    = jsonResponse("The API token lacks the required scope, or the snippet belongs to someone else.", ref("Error"))
+	notFoundResponse     = jsonResponse("There is no such snippet, or it has expired.", ref("Error"))
+	validationResponse   = jsonResponse("The request failed validation. The fields property lists the problems by field.", ref("Error"))
+	serverErrorResponse  = jsonResponse("The server failed to process the request.", ref("Error"))
+)
+
+// authenticated lists the ways an operation may be authenticated: a bearer
+// API token or the session cookie. Operations open to everyone use
+// optionallyAuthenticated, whose empty requirement allows anonymous requests.
+var (
+	authenticated           = []map[string][]string{{"apiToken": {}}, {"session": {}}}
+	optionallyAuthenticated = []map[string][]string{{"apiToken": {}}, {"session": {}}, {}}
+)
+
+// components holds the schemas and security schemes the operations refer
+// to.
+var components = map[string]any{
+	"securitySchemes": map[string]any{
+		"apiToken": map[string]any{
+			"type":        "http",
+			"scheme":      "bearer",
+			"description": "A personal API token from the profile page. Read tokens may only call GET operations; write tokens may call any.",
+		},
+		"session": map[string]any{
+			"type":        "apiKey",
+			"in":          "cookie",
+			"name":        "session",
+			"description": "The session cookie set by logging in on the site. Requests other than GET must also send the page's CSRF token in an X-CSRF-Token header.",
+		},
+	},
+	"schemas": map[string]schema{
+		"Snippet": {
+			"type":     "object",
+			"required": []string{"id", "title", "content", "language", "author", "created", "expires"},
+			"properties": map[string]schema{
+				"id":       {"type": "integer"},
+				"title":    {"type": "string"},
+				"content":  {"type": "string"},
+				"language": {"type": "string", "description": "The language used for highlighting, or empty for plain text."},
+				"tags":     {"type": "array", "items": schema{"type": "string"}},
+				"author": {
+					"type":        "object",
+					"nullable":    true,
+					"description": "Null for snippets created before authorship was recorded.",
+					"properties": map[string]schema{
+						"id":   {"type": "integer"},
+						"name": {"type": "string"},
+					},
+				},
+				"created": {"type": "string", "format": "date-time"},
+				"expires": {"type": "string", "format": "date-time"},
+			},
+		},
+		"SnippetInput": {
+			"type":     "object",
+			"required": []string{"title", "content", "expires"},
+			"properties": map[string]schema{
+				"title":    {"type": "string", "maxLength": 100},
+				"content":  {"type": "string"},
+				"language": {"type": "string", "description": "One of the supported languages. Detected from the content when empty."},
+				"expires":  {"type": "integer", "enum": []int{1, 7, 365}, "description": "Days until the snippet expires."},
+				"tags": {
+					"type":     "array",
+					"maxItems": 5,
+					"items":    schema{"type": "string", "pattern": tagRX.String()},
+				},
+			},
+			"additionalProperties": false,
+		},
+		"SnippetList": {
+			"type":     "object",
+			"required": []string{"snippets", "next", "prev"},
+			"properties": map[string]schema{
+				"snippets": {"type": "array", "items": ref("Snippet")},
+				"next":     {"type": "string", "nullable": true, "description": "Cursor for the after parameter, or null on the last page."},
+				"prev":     {"type": "string", "nullable": true, "description": "Cursor for the before parameter, or null on the first page."},
+			},
+		},
+		"User": {
+			"type":     "object",
+			"required": []string{"id", "name", "email", "created"},
+			"properties": map[string]schema{
+				"id":      {"type": "integer"},
+				"name":    {"type": "string"},
+				"email":   {"type": "string", "format": "email"},
+				"created": {"type": "string", "format": "date-time"},
+			},
+		},
+		"Error": {
+			"type":     "object",
+			"required": []string{"error"},
+			"properties": map[string]schema{
+				"error": {
+					"type":     "object",
+					"required": []string{"status", "message"},
+					"properties": map[string]schema{
+						"status":  {"type": "integer"},
+						"message": {"type": "string"},
+						"fields": {
+							"type":                 "object",
+							"description":          "Validation errors keyed by field name.",
+							"additionalProperties": schema{"type": "array", "items": schema{"type": "string"}},
+						},
+					},
+				},
+			},
+		},
+	},
+}
+
+// wrapped returns a schema for an object with a single property holding v,
+// like {"snippet": {...}}.
+func wrapped(name string, v schema) schema {
+	return schema{
+		"type":       "object",
+		"required":   []string{name},
+		"properties": map[string]schema{name: v},
+	}
+}
+
+// listSnippetsParams are the query string parameters of the snippet listing,
+// as read by snippetFilter.
+var listSnippetsParams = []parameter{
+	{Name: "sort", In: "query", Schema: schema{"type": "string", "enum": []string{"newest", "oldest"}}},
+	{Name: "expires", In: "query", Description: "Only snippets expiring within this many days.", Schema: schema{"type": "string", "enum": []string{"1", "7", "30"}}},
+	{Name: "author", In: "query", Description: "Only snippets by this user ID.", Schema: schema{"type": "integer", "minimum": 1}},
+	{Name: "limit", In: "query", Schema: schema{"type": "integer", "minimum": 1, "maximum": maxPageSize, "default": defaultPageSize}},
+	{Name: "after", In: "query", Description: "The next cursor of the previous page.", Schema: schema{"type": "string"}},
+	{Name: "before", In: "query", Description: "The prev cursor of the following page.", Schema: schema{"type": "string"}},
+}
+
+// The operations of the API routes registered in routes.go.
+var (
+	listSnippetsOp = &operation{
+		Summary:     "List snippets",
+		Description: "Returns a page of unexpired snippets. Follow the next and prev cursors to page through them.",
+		Tags:        []string{"snippets"},
+		Parameters:  listSnippetsParams,
+		Responses: map[string]response{
+			"200": jsonResponse("A page of snippets.", ref("SnippetList")),
+			"401": invalidTokenResponse,
+			"422": validationResponse,
+			"500": serverErrorResponse,
+		},
+		Security: optionallyAuthenticated,
+	}
+	createSnippetOp = &operation{
+		Summary:     "Create a snippet",
+		Description: "Needs the write scope.",
+		Tags:        []string{"snippets"},
+		RequestBody: jsonBody(ref("SnippetInput")),
+		Responses: map[string]response{
+			"201": jsonResponse("The new snippet. Its URL is in the Location header.", wrapped("snippet", ref("Snippet"))),
+			"400": badRequestResponse,
+			"401": unauthorizedResponse,
+			"403": forbiddenResponse,
+			"422": validationResponse,
+			"500": serverErrorResponse,
+		},
+		Security: authenticated,
+	}
+	getSnippetOp = &operation{
+		Summary: "Get a snippet",
+		Tags:    []string{"snippets"},
+		Responses: map[string]response{
+			"200": jsonResponse("The snippet.", wrapped("snippet", ref("Snippet"))),
+			"401": invalidTokenResponse,
+			"404": notFoundResponse,
+			"500": serverErrorResponse,
+		},
+		Security: optionallyAuthenticated,
+	}
+	updateSnippetOp = &operation{
+		Summary:     "Replace a snippet",
+		Description: "Only the snippet's author may change it. Needs the write scope.",
+		Tags:        []string{"snippets"},
+		RequestBody: jsonBody(ref("SnippetInput")),
+		Responses: map[string]response{
+			"200": jsonResponse("The updated snippet.", wrapped("snippet", ref("Snippet"))),
+			"400": badRequestResponse,
+			"401": unauthorizedResponse,
+			"403": forbiddenResponse,
+			"404": notFoundResponse,
+			"422": validationResponse,
+			"500": serverErrorResponse,
+		},
+		Security: authenticated,
+	}
+	deleteSnippetOp = &operation{
+		Summary:     "Delete a snippet",
+		Description: "Only the snippet's author may delete it. Needs the write scope.",
+		Tags:        []string{"snippets"},
+		Responses: map[string]response{
+			"204": {Description: "The snippet was deleted."},
+			"401": unauthorizedResponse,
+			"403": forbiddenResponse,
+			"404": notFoundResponse,
+			"500": serverErrorResponse,
+		},
+		Security: authenticated,
+	}
+	currentUserOp = &operation{
+		Summary: "Get the authenticated user",
+		Tags:    []string{"users"},
+		Responses: map[string]response{
+			"200": jsonResponse("The user the request is authenticated as.", wrapped("user", ref("User"))),
+			"401": unauthorizedResponse,
+			"500": serverErrorResponse,
+		},
+		Security: authenticated,
+	}
+	pasteOp = &operation{
+		Summary:     "Paste a snippet from the command line",
+		Description: "Creates a snippet from the raw request body. Only API tokens with the write scope are accepted.",
+		Tags:        []string{"snippets"},
+		Parameters: []parameter{
+			{Name: "title", In: "query", Description: "Defaults to Untitled.", Schema: schema{"type": "string", "maxLength": 100}},
+			{Name: "expires", In: "query", Description: "Days until the snippet expires.", Schema: schema{"type": "string", "enum": []string{"1", "7", "365"}, "default": "7"}},
+			{Name: "language", In: "query", Description: "Detected from the content when empty.", Schema: schema{"type": "string"}},
+		},
+		RequestBody: &requestBody{Required: true, Content: map[string]mediaType{"text/plain": {schema{"type": "string"}}}},
+		Responses: map[string]response{
+			"201": textResponse("The URL of the new snippet."),
+			"400": textResponse("The request failed validation. Each line names a field and its problem."),
+			"401": unauthorizedResponse,
+			"403": forbiddenResponse,
+			"413": textResponse("The body is larger than 1MB."),
+			"500": textResponse("The server failed to process the request."),
+		},
+		Security: []map[string][]string{{"apiToken": {}}},
+	}
+	openAPIDocOp = &operation{
+		Summary: "Get this OpenAPI document",
+		Tags:    []string{"meta"},
+		Responses: map[string]response{
+			"200": jsonResponse("The OpenAPI 3 document describing the API.", schema{"type": "object"}),
+		},
+	}
+)
+
+// apiRouter registers API routes on a mux and records their operations in an
+// OpenAPI document.
+type apiRouter struct {
+	mux *routeMux
+	doc *openAPIDoc
+}
+
+func newAPIRouter(mux *routeMux) *apiRouter {
+	return &apiRouter{
+		mux: mux,
+		doc: &openAPIDoc{
+			OpenAPI: "3.0.3",
+			Info: openAPIInfo{
+				Title:       "Snippetbox API",
+				Version:     "1",
+				Description: "Create, read, update and delete snippets. Every error response uses the Error schema.",
+			},
+			Paths:      map[string]map[string]*operation{},
+			Components: components,
+		},
+	}
+}
+
+// paramRX matches the named parameters in a pat pattern, like :id.
+var paramRX = regexp.MustCompile(`:(\w+)`)
+
+// handle registers h for method and pattern, which uses pat's syntax, and
+// adds op to the document. Path parameters are described automatically. It
+// panics if op is missing its summary or responses, in the same way
+// http.ServeMux panics on bad patterns, so an undocumented route stops the
+// application from starting.
+func (ar *apiRouter) handle(method, pattern string, h http.Handler, op *operation) {
+	if op == nil || op.Summary == "" || len(op.Responses) == 0 {
+		panic(fmt.Sprintf("openapi: %s %s needs an operation with a summary and responses", method, pattern))
+	}
+
+	// Work on a copy, as the operations are shared package variables.
+	op = &operation{
+		Summary:     op.Summary,
+		Description: op.Description,
+		Tags:        op.Tags,
+		Parameters:  append([]parameter{}, op.Parameters...),
+		RequestBody: op.RequestBody,
+		Responses:   op.Responses,
+		Security:    op.Security,
+	}
+
+	path := paramRX.ReplaceAllString(pattern, "{$1}")
+	for _, m := range paramRX.FindAllStringSubmatch(pattern, -1) {
+		op.Parameters = append(op.Parameters, parameter{
+			Name:     m[1],
+			In:       "path",
+			Required: true,
+			Schema:   schema{"type": "integer", "minimum": 1},
+		})
+	}
+
+	if ar.doc.Paths[path] == nil {
+		ar.doc.Paths[path] = map[string]*operation{}
+	}
+	if _, ok := ar.doc.Paths[path][strings.ToLower(method)]; ok {
+		panic(fmt.Sprintf("openapi: %s %s is registered twice", method, pattern))
+	}
+	ar.doc.Paths[path][strings.ToLower(method)] = op
+
+	if method == http.MethodGet {
+		ar.mux.Get(pattern, h)
+	} else {
+		ar.mux.Add(method, pattern, h)
+	}
+}
+
+// serveDoc returns a handler which sends the OpenAPI document. The document
+// is only encoded on the first request, so it also lists the routes
+// registered after the handler, the document's own one included.
+func (ar *apiRouter) serveDoc() http.Handler {
+	encode := sync.OnceValues(func() ([]byte, error) {
+		return json.MarshalIndent(ar.doc, "", "\t")
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := encode()
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	})
+}
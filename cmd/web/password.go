@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"vincellauderes.net/snippetbox/pkg/forms"
+	"vincellauderes.net/snippetbox/pkg/mailer"
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+func (app *application) forgotPasswordForm(w http.ResponseWriter, r *http.Request) {
+	app.render(w, r, "forgot.page.tmpl", &templateData{
+		Form: forms.New(nil),
+	})
+}
+
+// forgotPassword emails a password reset link to the given address. The
+// response is the same whether or not there's an account for it, so the
+// form can't be used to find out who has one.
+func (app *application) forgotPassword(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("email")
+	form.MatchesPattern("email", forms.EmailRX)
+
+	if !form.Valid() {
+		app.render(w, r, "forgot.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	user, err := app.userss.ByEmail(form.Get("email"))
+	if err != nil && err != models.ErrNoRecord {
+		app.serverError(w, err)
+		return
+	}
+
+	if user != nil && app.resetEmails.Take(strings.ToLower(user.Email)).Allowed {
+		token, err := app.resets.Insert(user.ID)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		app.sendMail(mailer.Message{
+			To:      user.Email,
+			Subject: "Reset your Snippetbox password",
+			Body:    resetEmailBody(user.Name, app.baseURL+"/user/password/reset?token="+url.QueryEscape(token)),
+		})
+	}
+
+	app.sessions.Put(r, "flash", "If there's an account for that address, we've emailed it a link to reset the password.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
+
+// resetEmailBody returns the text of the password reset email.
+func resetEmailBody(name, link string) string {
+	return fmt.Sprintf(`Hi %s,
+
+Someone asked to reset the password of your Snippetbox account. If it was
+you, follow this link within %s to choose a new password:
+
+%s
+
+If it wasn't you, you can ignore this email and your password stays the same.
+`, name, humanDuration(models.PasswordResetTTL), link)
+}
+
+// sendMail sends an email in the background, so a slow mail server doesn't
+// hold up the response or reveal through its timing whether an email was
+// sent at all. Failures can only be logged.
+func (app *application) sendMail(msg mailer.Message) {
+	app.mailing.Add(1)
+	go func() {
+		defer app.mailing.Done()
+		defer func() {
+			if err := recover(); err != nil {
+				app.errorLog.Printf("sending email: %v", err)
+			}
+		}()
+
+		if err := app.mailer.Send(msg); err != nil {
+			app.errorLog.Printf("sending email: %v", err)
+		}
+	}()
+}
+
+func (app *application) resetPasswordForm(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(url.Values{"token": {r.URL.Query().Get("token")}})
+
+	_, err := app.resets.UserID(form.Get("token"))
+	if err == models.ErrNoRecord {
+		form.Errors.Add("token", "This link is invalid or has expired.")
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.render(w, r, "reset.page.tmpl", &templateData{Form: form})
+}
+
+// resetPassword sets a new password using a token from a reset email.
+func (app *application) resetPassword(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("token", "password")
+	form.MinLength("password", 10)
+	form.MaxBytes("password", models.MaxPasswordBytes)
+
+	if !form.Valid() {
+		app.render(w, r, "reset.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	err = app.resets.Reset(form.Get("token"), form.Get("password"))
+	if err == models.ErrNoRecord {
+		form.Errors.Add("token", "This link is invalid or has expired.")
+		app.render(w, r, "reset.page.tmpl", &templateData{Form: form})
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessions.Put(r, "flash", "Your password has been reset. Please log in.")
+	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+}
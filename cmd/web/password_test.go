@@ -0,0 +1,77 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestResetPassword(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	if err := app.userss.Insert("Alice", "alice@example.com", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	user, err := app.userss.ByEmail("alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := app.resets.Insert(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		password string
+		wantCode int
+		wantBody string
+	}{
+		{"Too short", token, "short", http.StatusOK, "This field is too short"},
+		// 40 characters, but 80 bytes, which bcrypt can't hash.
+		{"Too long for bcrypt", token, strings.Repeat("é", 40), http.StatusOK, "maximum is 72 bytes"},
+		{"Unknown token", "nope", "new-password-123", http.StatusOK, "This link is invalid or has expired."},
+		{"Valid", token, "new-password-123", http.StatusSeeOther, ""},
+		{"Used token", token, "new-password-456", http.StatusOK, "This link is invalid or has expired."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, body := ts.postForm(t, "/user/password/reset", url.Values{"token": {tt.token}, "password": {tt.password}})
+			if rs.StatusCode != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, rs.StatusCode)
+			}
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	if _, err := app.userss.Authenticate("alice@example.com", "new-password-123"); err != nil {
+		t.Errorf("logging in with the new password: %v", err)
+	}
+}
+
+func TestSignupPasswordTooLong(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	rs, body := ts.postForm(t, "/user/signup", url.Values{
+		"name":     {"Alice"},
+		"email":    {"alice@example.com"},
+		"password": {strings.Repeat("é", 40)},
+	})
+	if rs.StatusCode != http.StatusOK || !strings.Contains(body, "maximum is 72 bytes") {
+		t.Errorf("got %d; want the form again with an error", rs.StatusCode)
+	}
+}
+
+func TestResetEmailBody(t *testing.T) {
+	body := resetEmailBody("Alice", "https://snippetbox.test/user/password/reset?token=x")
+	if !strings.Contains(body, "within 1 hour") {
+		t.Errorf("want the link's lifetime in hours; got:\n%s", body)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"vincellauderes.net/snippetbox/pkg/forms"
+)
+
+// maxPasteSize caps the body accepted by the paste endpoint.
+const maxPasteSize = 1 << 20
+
+// paste creates a snippet from the raw request body, so that
+//
+//	cat main.go | curl --data-binary @- -H 'Authorization: Bearer <token>' https://host/paste
+//
+// works like other pastebins. The title, expires and language query
+// parameters are optional, and the new snippet's URL, built from the
+// configured base URL rather than the Host header, is sent back as plain
+// text. The authenticateToken middleware has already checked the token.
+func (app *application) paste(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPasteSize))
+	if err != nil {
+		app.clientError(w, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	q := r.URL.Query()
+	form := forms.New(url.Values{
+		"title":    {q.Get("title")},
+		"content":  {string(body)},
+		"expires":  {q.Get("expires")},
+		"language": {q.Get("language")},
+	})
+	if form.Get("title") == "" {
+		form.Set("title", "Untitled")
+	}
+	if form.Get("expires") == "" {
+		form.Set("expires", "7")
+	}
+
+	// Apply the same rules as the HTML form.
+	validateSnippetForm(form)
+
+	if !form.Valid() {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusBadRequest)
+		for _, field := range []string{"title", "content", "expires", "language"} {
+			for _, msg := range form.Errors[field] {
+				fmt.Fprintf(w, "%s: %s\n", field, msg)
+			}
+		}
+		return
+	}
+
+	id, err := app.snippets.Insert(app.authenticatedUser(r).ID, form.Get("title"), form.Get("content"), snippetLanguage(form), form.Get("expires"), nil)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	fmt.Fprintf(w, "%s/snippets/%d\n", app.baseURL, id)
+}
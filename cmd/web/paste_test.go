@@ -0,0 +1,57 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+func TestPasteURLUsesBaseURL(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	if err := app.userss.Insert("Alice", "alice@example.com", "password123"); err != nil {
+		t.Fatal(err)
+	}
+	userID, err := app.userss.Authenticate("alice@example.com", "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := app.tokens.Insert(userID, "cli", models.ScopeWrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rs, _ := ts.do(t, http.MethodPost, "/paste", strings.NewReader("hello"), nil)
+	if rs.StatusCode != http.StatusUnauthorized {
+		t.Errorf("no token: want %d; got %d", http.StatusUnauthorized, rs.StatusCode)
+	}
+
+	// A forged Host header mustn't end up in the URL sent back.
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/paste?title=hello", strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Host = "evil.example.com"
+
+	rs, err = ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	if rs.StatusCode != http.StatusCreated {
+		t.Fatalf("want %d; got %d", http.StatusCreated, rs.StatusCode)
+	}
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := app.baseURL + "/snippets/1\n"; string(body) != want {
+		t.Errorf("want body %q; got %q", want, body)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// reapExpiredSnippets deletes expired snippets on start and then
+// periodically until ctx is cancelled. Each run removes rows in batches of
+// batchSize, so a large backlog never holds a long-running lock on the
+// snippets table.
+func (app *application) reapExpiredSnippets(ctx context.Context, interval time.Duration, batchSize int) {
+	// Purge once straight away, so snippets still get purged when the
+	// process restarts more often than every interval.
+	app.reapOnce(ctx, batchSize)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			app.reapOnce(ctx, batchSize)
+		}
+	}
+}
+
+// reapOnce deletes batches of expired snippets until a batch comes back short,
+// then logs how many rows were removed in total.
+func (app *application) reapOnce(ctx context.Context, batchSize int) {
+	total := 0
+	for ctx.Err() == nil {
+		n, err := app.snippets.DeleteExpired(batchSize)
+		if err != nil {
+			app.errorLog.Printf("reaper: %s", err)
+			break
+		}
+		total += n
+		if n < batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		app.infoLog.Printf("Reaper removed %d expired snippets", total)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+// reapRecorder passes DeleteExpired through to the wrapped store, sending
+// the number of rows each call deleted to deleted.
+type reapRecorder struct {
+	models.SnippetStore
+	deleted chan int
+}
+
+func (r *reapRecorder) DeleteExpired(limit int) (int, error) {
+	n, err := r.SnippetStore.DeleteExpired(limit)
+	r.deleted <- n
+	return n, err
+}
+
+func TestReapExpiredSnippetsOnStart(t *testing.T) {
+	app := newTestApplication(t)
+
+	for _, expires := range []string{"-1", "-1", "-1", "7"} {
+		if _, err := app.snippets.Insert(0, "title", "content", "", expires, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	recorder := &reapRecorder{SnippetStore: app.snippets, deleted: make(chan int, 10)}
+	app.snippets = recorder
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The interval is far longer than the test, so only the purge on
+		// start can delete anything.
+		app.reapExpiredSnippets(ctx, time.Hour, 2)
+	}()
+
+	// Batches of 2 delete the 3 expired snippets in two calls.
+	total := 0
+	for _, want := range []int{2, 1} {
+		select {
+		case n := <-recorder.deleted:
+			if n != want {
+				t.Errorf("batch deleted %d snippets; want %d", n, want)
+			}
+			total += n
+		case <-time.After(5 * time.Second):
+			t.Fatalf("reaper didn't run on start; %d snippets deleted so far", total)
+		}
+	}
+
+	cancel()
+	<-done
+
+	if n, _ := app.snippets.DeleteExpired(10); n != 0 {
+		t.Errorf("%d expired snippets left", n)
+	}
+}
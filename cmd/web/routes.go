@@ -1,63 +1,135 @@
 package main
 
 import (
-	"encoding/json"
 	"net/http"
 
 	"github.com/bmizerany/pat"
 	"github.com/justinas/alice"
+	"vincellauderes.net/snippetbox/pkg/models"
+	"vincellauderes.net/snippetbox/pkg/ratelimit"
 )
 
-func check(e error) {
-	if e != nil {
-		panic(e)
-	}
+// routeMux is a pat mux which remembers the method and pattern of every
+// route added to it, so tests can check the API routes against the OpenAPI
+// document. Only the registration methods used here are provided, so a route
+// can't be added without being remembered.
+type routeMux struct {
+	mux    *pat.PatternServeMux
+	routes []route
 }
 
-// In Go, struct is just like a class in other Programming Language.
-// To use struct you need to create an instance of that Struct
-// t := TestRoute{}
-type TestRoute struct{}
+type route struct {
+	method  string
+	pattern string
+}
+
+func newRouteMux() *routeMux {
+	return &routeMux{mux: pat.New()}
+}
 
-func (t *TestRoute) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	d := map[string]string{
-		"message": "Hello World",
-	}
+func (m *routeMux) Add(method, pattern string, h http.Handler) {
+	m.routes = append(m.routes, route{method, pattern})
+	m.mux.Add(method, pattern, h)
+}
 
-	json, err := json.Marshal(d)
-	check(err)
+// Get registers h for GET requests and, like pat's Get, HEAD requests.
+func (m *routeMux) Get(pattern string, h http.Handler) {
+	m.Add(http.MethodHead, pattern, h)
+	m.Add(http.MethodGet, pattern, h)
+}
+
+func (m *routeMux) Post(pattern string, h http.Handler) {
+	m.Add(http.MethodPost, pattern, h)
+}
 
-	w.Write(json)
+func (m *routeMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mux.ServeHTTP(w, r)
 }
 
 // Create application routes using mux
 func (app *application) routes() http.Handler {
 	// Create a middleware chain containing our 'standard' middleware.
 	// which will be used for every request our application receives.
-	standardMiddleware := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
+	standardMiddleware := alice.New(app.recoverPanic, app.logRequest, app.secureHeaders)
 
-	t := &TestRoute{}
+	mux, _ := app.router()
+	return standardMiddleware.Then(mux)
+}
+
+// router registers every route on a new mux. It also returns the API router,
+// whose OpenAPI document describes the API routes among them.
+func (app *application) router() (*routeMux, *apiRouter) {
+	// Rate limits. Every page is limited per IP address to an average of 10
+	// requests a second, in bursts of up to 40. Routes which create or change
+	// things are also limited per user to one request every 2 seconds, in
+	// bursts of up to 10.
+	browseLimit := app.rateLimit(ratelimit.New(10, 40), app.ipKey)
+	writeLimit := app.rateLimit(ratelimit.New(0.5, 10), app.userKey)
 
 	// Create a new middleware chain containing the middleware specific to
-	// our dynamic application routes. For now, this chain will only contain
-	// the sessions middleware but we'll add more to it later.
-	dynamicMiddleWare := alice.New(app.sessions.Enable)
+	// our dynamic application routes: the rate limit, the sessions
+	// middleware, the CSRF check, and authenticate which loads the logged-in
+	// user from the session.
+	dynamicMiddleWare := alice.New(browseLimit, app.sessions.Enable, app.noSurf, app.authenticate)
 
-	mux := pat.New()
+	mux := newRouteMux()
 	mux.Get("/", dynamicMiddleWare.ThenFunc(app.home))
-	mux.Get("/testroute", dynamicMiddleWare.ThenFunc(t.ServeHTTP))
+	mux.Get("/snippets", dynamicMiddleWare.ThenFunc(app.listSnippets))
+	mux.Get("/search", dynamicMiddleWare.ThenFunc(app.search))
 	mux.Get("/snippets/create", dynamicMiddleWare.Append(app.requireAuthenticatedUser).ThenFunc(app.createSnippetForm))
-	mux.Get("/users", dynamicMiddleWare.ThenFunc(app.users))
-	// mux.Get("/snippets/create", dynamicMiddleWare.ThenFunc(app.users))
-	mux.Post("/snippets/create", dynamicMiddleWare.Append(app.requireAuthenticatedUser).ThenFunc(app.createSnippet))
+	mux.Post("/snippets/create", dynamicMiddleWare.Append(app.requireAuthenticatedUser, writeLimit).ThenFunc(app.createSnippet))
 	mux.Get("/snippets/:id", dynamicMiddleWare.ThenFunc(app.showSnippet))
+	mux.Get("/snippets/:id/edit", dynamicMiddleWare.Append(app.requireAuthenticatedUser).ThenFunc(app.editSnippetForm))
+	mux.Post("/snippets/:id/edit", dynamicMiddleWare.Append(app.requireAuthenticatedUser, writeLimit).ThenFunc(app.editSnippet))
+	mux.Post("/snippets/:id/delete", dynamicMiddleWare.Append(app.requireAuthenticatedUser, writeLimit).ThenFunc(app.deleteSnippet))
+	mux.Get("/snippets/:id/raw", browseLimit(http.HandlerFunc(app.rawSnippet)))
+	mux.Get("/snippets/:id/download", browseLimit(http.HandlerFunc(app.downloadSnippet)))
+	mux.Get("/snippets/:id/history", dynamicMiddleWare.ThenFunc(app.snippetHistory))
+	mux.Get("/snippets/:id/diff", dynamicMiddleWare.ThenFunc(app.snippetDiff))
+	mux.Get("/tags/:name", dynamicMiddleWare.ThenFunc(app.showTag))
 
-	// Add the five new routes.
+	// Routes for signing up, logging in and out, and the user's own profile.
 	mux.Get("/user/signup", dynamicMiddleWare.ThenFunc(app.signupUserForm))
 	mux.Post("/user/signup", dynamicMiddleWare.ThenFunc(app.signupUser))
 	mux.Get("/user/login", dynamicMiddleWare.ThenFunc(app.loginUserForm))
 	mux.Post("/user/login", dynamicMiddleWare.ThenFunc(app.loginUser))
+	mux.Get("/user/login/totp", dynamicMiddleWare.ThenFunc(app.loginTOTPForm))
+	mux.Post("/user/login/totp", dynamicMiddleWare.ThenFunc(app.loginTOTP))
+	mux.Get("/user/password/forgot", dynamicMiddleWare.ThenFunc(app.forgotPasswordForm))
+	mux.Post("/user/password/forgot", dynamicMiddleWare.Append(writeLimit).ThenFunc(app.forgotPassword))
+	mux.Get("/user/password/reset", dynamicMiddleWare.ThenFunc(app.resetPasswordForm))
+	mux.Post("/user/password/reset", dynamicMiddleWare.Append(writeLimit).ThenFunc(app.resetPassword))
 	mux.Post("/user/logout", dynamicMiddleWare.ThenFunc(app.logoutUser))
+	mux.Get("/user/profile", dynamicMiddleWare.Append(app.requireAuthenticatedUser).ThenFunc(app.userProfile))
+	mux.Post("/user/tokens", dynamicMiddleWare.Append(app.requireAuthenticatedUser, writeLimit).ThenFunc(app.createToken))
+	mux.Get("/user/2fa", dynamicMiddleWare.Append(app.requireAuthenticatedUser).ThenFunc(app.twoFactorForm))
+	mux.Post("/user/2fa/enable", dynamicMiddleWare.Append(app.requireAuthenticatedUser, writeLimit).ThenFunc(app.enableTwoFactor))
+	mux.Post("/user/2fa/disable", dynamicMiddleWare.Append(app.requireAuthenticatedUser, writeLimit).ThenFunc(app.disableTwoFactor))
+	mux.Post("/user/tokens/:id/delete", dynamicMiddleWare.Append(app.requireAuthenticatedUser, writeLimit).ThenFunc(app.deleteToken))
+
+	// The paste endpoint is for command-line clients, which authenticate
+	// with an API token instead of a session. Like the JSON API below, it's
+	// registered through api so that it appears in the OpenAPI document.
+	api := newAPIRouter(mux)
+	pasteMiddleware := alice.New(browseLimit, app.authenticateToken, app.requireAPIUser, app.requireScope(models.ScopeWrite), writeLimit)
+	api.handle("POST", "/paste", pasteMiddleware.ThenFunc(app.paste), pasteOp)
+
+	// The JSON API. Requests are authenticated either by the session, like
+	// the rest of the site, or by an API token whose scope must allow them.
+	apiMiddleware := dynamicMiddleWare.Append(app.authenticateToken)
+	readMiddleware := apiMiddleware.Append(app.requireAPIUser, app.requireScope(models.ScopeRead))
+	writeMiddleware := apiMiddleware.Append(app.requireAPIUser, app.requireScope(models.ScopeWrite), writeLimit)
+	api.handle("GET", "/api/v1/snippets", apiMiddleware.ThenFunc(app.apiListSnippets), listSnippetsOp)
+	api.handle("POST", "/api/v1/snippets", writeMiddleware.ThenFunc(app.apiCreateSnippet), createSnippetOp)
+	api.handle("GET", "/api/v1/snippets/:id", apiMiddleware.ThenFunc(app.apiGetSnippet), getSnippetOp)
+	api.handle("PUT", "/api/v1/snippets/:id", writeMiddleware.ThenFunc(app.apiUpdateSnippet), updateSnippetOp)
+	api.handle("DELETE", "/api/v1/snippets/:id", writeMiddleware.ThenFunc(app.apiDeleteSnippet), deleteSnippetOp)
+	api.handle("GET", "/api/v1/user", readMiddleware.ThenFunc(app.apiCurrentUser), currentUserOp)
+	api.handle("GET", "/api/openapi.json", api.serveDoc(), openAPIDocOp)
+
+	// Browsers post Content-Security-Policy violation reports here. There's
+	// no session or CSRF token involved, so it only needs the rate limit.
+	mux.Post("/csp-report", browseLimit(http.HandlerFunc(app.cspReport)))
 
 	// Create a file server which serves files out of the "./ui/static" directory
 	// Note that the path given to the http.Dir function is relative to the provider
@@ -65,5 +137,5 @@ func (app *application) routes() http.Handler {
 	fileServer := http.FileServer(http.Dir("./ui/static"))
 	mux.Get("/static/", http.StripPrefix("/static", fileServer))
 
-	return standardMiddleware.Then(mux)
+	return mux, api
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// TestAPIRoutesDocumented fails for any API route registered on the mux
+// without going through apiRouter.handle, which would leave it out of the
+// OpenAPI document.
+func TestAPIRoutesDocumented(t *testing.T) {
+	app := newTestApplication(t)
+	app.routes()
+	mux, api := app.router()
+
+	found := 0
+	for _, rt := range mux.routes {
+		if !strings.HasPrefix(rt.pattern, "/api/") || rt.method == http.MethodHead {
+			continue
+		}
+		found++
+
+		path := paramRX.ReplaceAllString(rt.pattern, "{$1}")
+		if _, ok := api.doc.Paths[path][strings.ToLower(rt.method)]; !ok {
+			t.Errorf("%s %s is registered but missing from the OpenAPI document", rt.method, rt.pattern)
+		}
+	}
+	if found == 0 {
+		t.Fatal("no API routes registered")
+	}
+
+	// And the other way round: everything documented is served.
+	registered := map[route]bool{}
+	for _, rt := range mux.routes {
+		registered[rt] = true
+	}
+	for path, ops := range api.doc.Paths {
+		pattern := strings.NewReplacer("{", ":", "}", "").Replace(path)
+		for method := range ops {
+			if !registered[route{strings.ToUpper(method), pattern}] {
+				t.Errorf("%s %s is documented but not registered", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	rs, body := ts.get(t, "/api/openapi.json")
+	if rs.StatusCode != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, rs.StatusCode)
+	}
+
+	var doc openAPIDoc
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := doc.Paths["/api/openapi.json"]["get"]; !ok {
+		t.Error("the document doesn't describe itself")
+	}
+
+	// Operations open to anonymous requests still reject bad API tokens.
+	for _, path := range []string{"/api/v1/snippets", "/api/v1/snippets/{id}"} {
+		if _, ok := doc.Paths[path]["get"].Responses["401"]; !ok {
+			t.Errorf("GET %s doesn't document the 401 for invalid tokens", path)
+		}
+	}
+}
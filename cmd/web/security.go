@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// securityPolicy holds the security headers sent with every response. An
+// empty field leaves its header out.
+type securityPolicy struct {
+	// ContentSecurityPolicy may contain {nonce}, which is replaced with a
+	// fresh random nonce for each request. Templates get the same nonce as
+	// CSPNonce, for the script and style tags they are allowed to include.
+	ContentSecurityPolicy string
+	// CSPReportOnly sends the policy as Content-Security-Policy-Report-Only,
+	// so browsers only report violations to /csp-report instead of
+	// blocking them. Useful to try out a policy change.
+	CSPReportOnly           bool
+	StrictTransportSecurity string
+	ReferrerPolicy          string
+	PermissionsPolicy       string
+	CrossOriginOpenerPolicy string
+}
+
+// defaultSecurityPolicy is the policy used unless flags change it. Scripts
+// and styles have to come from this site, or carry the request's nonce.
+// Images may also come from other HTTPS sites, since Markdown snippets can
+// link to them.
+var defaultSecurityPolicy = securityPolicy{
+	ContentSecurityPolicy: strings.Join([]string{
+		"default-src 'self'",
+		"script-src 'self' 'nonce-{nonce}'",
+		"style-src 'self' 'nonce-{nonce}'",
+		"img-src 'self' https: data:",
+		"object-src 'none'",
+		"base-uri 'self'",
+		"form-action 'self'",
+		"frame-ancestors 'none'",
+		"report-uri /csp-report",
+	}, "; "),
+	StrictTransportSecurity: "max-age=63072000; includeSubDomains",
+	ReferrerPolicy:          "strict-origin-when-cross-origin",
+	PermissionsPolicy:       "camera=(), microphone=(), geolocation=(), payment=(), usb=()",
+	CrossOriginOpenerPolicy: "same-origin",
+}
+
+// newNonce returns a random value for a Content-Security-Policy nonce.
+func newNonce() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// maxCSPReportSize caps the size of the violation reports accepted.
+const maxCSPReportSize = 64 << 10
+
+// cspViolation holds the fields of a violation report which are logged. The
+// rest of the report is ignored.
+type cspViolation struct {
+	DocumentURI       string `json:"document-uri"`
+	ViolatedDirective string `json:"violated-directive"`
+	BlockedURI        string `json:"blocked-uri"`
+	SourceFile        string `json:"source-file"`
+	LineNumber        int    `json:"line-number"`
+}
+
+// cspReport collects the Content-Security-Policy violation reports browsers
+// send to /csp-report and writes them to the info log. Anyone can post
+// here, so the fields are quoted to keep them from forging log lines.
+func (app *application) cspReport(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxCSPReportSize))
+	if err != nil {
+		app.clientError(w, http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var report struct {
+		Violation *cspViolation `json:"csp-report"`
+	}
+	if err := json.Unmarshal(body, &report); err != nil || report.Violation == nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	v := report.Violation
+	app.infoLog.Printf("CSP violation reported by %s: %q blocked %q on %q (%q line %d)",
+		app.clientIP(r), v.ViolatedDirective, v.BlockedURI, v.DocumentURI, v.SourceFile, v.LineNumber)
+	w.WriteHeader(http.StatusNoContent)
+}
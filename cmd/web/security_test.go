@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var (
+	headerNonceRX   = regexp.MustCompile(`script-src 'self' 'nonce-([^']+)'`)
+	templateNonceRX = regexp.MustCompile(`<script [^>]*nonce='([^']+)'`)
+)
+
+func TestCSPNonce(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		rs, body := ts.get(t, "/")
+		if rs.StatusCode != http.StatusOK {
+			t.Fatalf("want %d; got %d", http.StatusOK, rs.StatusCode)
+		}
+
+		csp := rs.Header.Get("Content-Security-Policy")
+		m := headerNonceRX.FindStringSubmatch(csp)
+		if m == nil {
+			t.Fatalf("no nonce in Content-Security-Policy %q", csp)
+		}
+		nonce := m[1]
+		if strings.Contains(csp, "{nonce}") || !strings.Contains(csp, "style-src 'self' 'nonce-"+nonce+"'") {
+			t.Errorf("want every {nonce} replaced with %q; got %q", nonce, csp)
+		}
+
+		m = templateNonceRX.FindStringSubmatch(body)
+		if m == nil {
+			t.Fatal("no nonce on the page's script tag")
+		}
+		if got := html.UnescapeString(m[1]); got != nonce {
+			t.Errorf("want the page's nonce %q to match the header's; got %q", nonce, got)
+		}
+
+		if seen[nonce] {
+			t.Errorf("nonce %q was used twice", nonce)
+		}
+		seen[nonce] = true
+	}
+}
+
+func TestCSPReportOnly(t *testing.T) {
+	for _, reportOnly := range []bool{false, true} {
+		app := newTestApplication(t)
+		app.security.CSPReportOnly = reportOnly
+		ts := newTestServer(t, app.routes())
+
+		rs, _ := ts.get(t, "/")
+		enforced := rs.Header.Get("Content-Security-Policy")
+		reported := rs.Header.Get("Content-Security-Policy-Report-Only")
+		if reportOnly && (enforced != "" || reported == "") {
+			t.Errorf("report only: want just the Report-Only header; got %q and %q", enforced, reported)
+		}
+		if !reportOnly && (enforced == "" || reported != "") {
+			t.Errorf("enforced: want just the Content-Security-Policy header; got %q and %q", enforced, reported)
+		}
+	}
+}
+
+func TestCSPReport(t *testing.T) {
+	app := newTestApplication(t)
+	var logged bytes.Buffer
+	app.infoLog = log.New(&logged, "", 0)
+	ts := newTestServer(t, app.routes())
+
+	valid := `{"csp-report": {"document-uri": "https://snippetbox.test/", "violated-directive": "script-src",` +
+		` "blocked-uri": "https://evil.example.com/x.js\nINFO\tforged line", "line-number": 3}}`
+	padded := valid[:len(valid)-1] + `, "padding": "` + strings.Repeat("x", maxCSPReportSize) + `"}`
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{"Report", valid, http.StatusNoContent},
+		{"Over 64KB", padded, http.StatusRequestEntityTooLarge},
+		{"Not JSON", "blocked", http.StatusBadRequest},
+		{"Not a report", `{"hello": "world"}`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logged.Reset()
+			header := http.Header{"Content-Type": {"application/csp-report"}}
+			rs, _ := ts.do(t, http.MethodPost, "/csp-report", strings.NewReader(tt.body), header)
+			if rs.StatusCode != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, rs.StatusCode)
+			}
+
+			if tt.wantCode == http.StatusNoContent {
+				if !strings.Contains(logged.String(), `"script-src" blocked "https://evil.example.com/x.js\nINFO\tforged line"`) {
+					t.Errorf("want the fields logged quoted; got %q", logged.String())
+				}
+				if strings.Contains(logged.String(), "\nINFO\tforged") {
+					t.Errorf("the report forged a log line: %q", logged.String())
+				}
+			} else if strings.Contains(logged.String(), "CSP violation") {
+				t.Errorf("want nothing logged; got %q", logged.String())
+			}
+		})
+	}
+}
@@ -3,20 +3,66 @@ package main
 import (
 	"html/template"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"vincellauderes.net/snippetbox/pkg/diff"
 	"vincellauderes.net/snippetbox/pkg/forms"
+	"vincellauderes.net/snippetbox/pkg/highlight"
+	"vincellauderes.net/snippetbox/pkg/markdown"
 	"vincellauderes.net/snippetbox/pkg/models"
 )
 
 // Add FormData and FormErrors fields to the templateData struct.
 type templateData struct {
-	AuthenticatedUser int
+	AuthenticatedUser *models.User
+	CSPNonce          string
+	CSRFToken         string
 	CurrentYear       int
 	Form              *forms.Form
 	Snippet           *models.Snippet
 	Snippets          []*models.Snippet
+	Revisions         []*models.Revision
+	DiffFrom          *models.Revision
+	DiffTo            *models.Revision
+	Diff              []diff.Hunk
+	DiffTooLarge      bool
+	Pagination        *pagination
+	Query             string
+	Search            *models.SearchResults
+	Tag               string
+	TagCloud          []cloudTag
 	Flash             string
+	// Token is a newly created API token, shown once.
+	Token  string
+	Tokens []*models.Token
+	// TOTPSecret and TOTPQRCode are the secret being set up for two-factor
+	// authentication, and a QR code of it as a data URL. RecoveryCodes are
+	// newly generated recovery codes, shown once.
+	TOTPSecret    string
+	TOTPQRCode    template.URL
+	RecoveryCodes []string
+}
+
+// cloudTag is a tag in the home page's tag cloud. Size runs from 1 to 5 and
+// grows with how many snippets carry the tag.
+type cloudTag struct {
+	Name  string
+	Count int
+	Size  int
+}
+
+// pagination holds the state of a paged listing: the filters in effect and
+// links to the neighbouring pages, which are empty when there's none.
+type pagination struct {
+	Limit    int
+	Sort     string
+	AuthorID int
+	Expires  string
+	NextURL  string
+	PrevURL  string
 }
 
 // Create a humanDate function which returns a nicely formatted string
@@ -25,11 +71,82 @@ func humanDate(t time.Time) string {
 	return t.Format("02 Jan 2006 at 15:04")
 }
 
+// excerpt returns up to about n characters of text, centred on the first
+// occurrence of any of the query's terms so the match is visible.
+func excerpt(text, query string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+
+	start := 0
+	if loc := termsRX(query); loc != nil {
+		if m := loc.FindStringIndex(text); m != nil {
+			start = max(utf8.RuneCountInString(text[:m[0]])-n/4, 0)
+		}
+	}
+	end := min(start+n, len(runes))
+	start = max(end-n, 0)
+
+	s := string(runes[start:end])
+	if start > 0 {
+		s = "…" + s
+	}
+	if end < len(runes) {
+		s += "…"
+	}
+	return s
+}
+
+// mark HTML-escapes text and wraps every occurrence of the query's terms in
+// <mark> tags, so search results show why they matched.
+func mark(text, query string) template.HTML {
+	rx := termsRX(query)
+	if rx == nil {
+		return template.HTML(template.HTMLEscapeString(text))
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range rx.FindAllStringIndex(text, -1) {
+		b.WriteString(template.HTMLEscapeString(text[last:m[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(text[m[0]:m[1]]))
+		b.WriteString("</mark>")
+		last = m[1]
+	}
+	b.WriteString(template.HTMLEscapeString(text[last:]))
+
+	return template.HTML(b.String())
+}
+
+// termsRX builds a case-insensitive pattern matching any of the query's
+// terms, or returns nil if it has none.
+func termsRX(query string) *regexp.Regexp {
+	terms := models.SearchTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	for i, t := range terms {
+		terms[i] = regexp.QuoteMeta(t)
+	}
+	return regexp.MustCompile(`(?i)` + strings.Join(terms, "|"))
+}
+
 // Initialize a template.FuncMap object and store it in a global variable. This
 // essentially a string-keyed map which acts as a lookup between the names of o
 // custom template functions and the functions themselves.
 var functions = template.FuncMap{
 	"humanDate": humanDate,
+	"excerpt":   excerpt,
+	"mark":      mark,
+	// highlight renders content for a language with syntax highlighting and
+	// linkable line numbers, and language turns a language value into its
+	// display name.
+	"highlight": highlight.HTML,
+	"language":  highlight.Label,
+	"languages": func() []highlight.Language { return highlight.Languages },
+	"markdown":  markdown.HTML,
 }
 
 func newTemplateCache(dir string) (map[string]*template.Template, error) {
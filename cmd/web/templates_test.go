@@ -0,0 +1,62 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMark(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		want  string
+	}{
+		{"No query", "An old silent pond", "", "An old silent pond"},
+		{"No match", "An old silent pond", "frog", "An old silent pond"},
+		{"Every term", "An old silent pond", "silent pond", "An old <mark>silent</mark> <mark>pond</mark>"},
+		{"Any case", "Pond, pond, POND", "pond", "<mark>Pond</mark>, <mark>pond</mark>, <mark>POND</mark>"},
+		{"Operators in the query", "An old silent pond", `"pond"* -silent`, "An old <mark>silent</mark> <mark>pond</mark>"},
+		{"Regexp characters", "a.b (c)", "a.b (c)", "<mark>a</mark>.<mark>b</mark> (<mark>c</mark>)"},
+		{"HTML in the text", "<b>pond</b> & 'frog'", "pond", "&lt;b&gt;<mark>pond</mark>&lt;/b&gt; &amp; &#39;frog&#39;"},
+		{"HTML in the match", "<script>alert(1)</script>", "script", "&lt;<mark>script</mark>&gt;alert(1)&lt;/<mark>script</mark>&gt;"},
+		{"HTML in the query", "<b>pond</b>", "<b>", "&lt;<mark>b</mark>&gt;pond&lt;/<mark>b</mark>&gt;"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(mark(tt.text, tt.query))
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	long := strings.Repeat("a", 50) + " pond " + strings.Repeat("b", 50)
+
+	tests := []struct {
+		name  string
+		text  string
+		query string
+		n     int
+		want  string
+	}{
+		{"Short", "An old silent pond", "pond", 100, "An old silent pond"},
+		{"Empty query", long, "", 10, "aaaaaaaaaa…"},
+		{"No match", long, "frog", 10, "aaaaaaaaaa…"},
+		{"Match a quarter of the way in", long, "pond", 12, "…aa pond bbbb…"},
+		{"Match at the end", strings.Repeat("a", 50) + " pond", "pond", 8, "…aaa pond"},
+		{"Multibyte", strings.Repeat("é", 20) + " pond", "pond", 8, "…ééé pond"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := excerpt(tt.text, tt.query, tt.n)
+			if got != tt.want {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"html"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golangcollege/sessions"
+	"vincellauderes.net/snippetbox/pkg/mailer"
+	"vincellauderes.net/snippetbox/pkg/models/mock"
+	"vincellauderes.net/snippetbox/pkg/ratelimit"
+	"vincellauderes.net/snippetbox/pkg/throttle"
+)
+
+// newTestApplication returns an application backed by the in-memory mocks,
+// with logging discarded and request rate limits off.
+func newTestApplication(t *testing.T) *application {
+	templateCache, err := newTemplateCache("./../../ui/html/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := sessions.New([]byte("3dSm5MnygFHh7XidAtbskXrjbwfoJcbJ"))
+	session.Lifetime = 12 * time.Hour
+
+	discard := log.New(io.Discard, "", 0)
+	users := &mock.UserModel{}
+	snippets := &mock.SnippetModel{Users: users}
+
+	return &application{
+		errorLog:      discard,
+		infoLog:       discard,
+		security:      defaultSecurityPolicy,
+		loginCodes:    throttle.New(loginEmailPolicy),
+		loginEmails:   throttle.New(loginEmailPolicy),
+		loginIPs:      throttle.New(loginIPPolicy),
+		baseURL:       "https://snippetbox.test",
+		mailer:        &mailer.Log{Logger: discard},
+		resetEmails:   ratelimit.New(resetEmailRate, resetEmailBurst),
+		resets:        &mock.PasswordResetModel{Users: users},
+		sessions:      session,
+		snippets:      snippets,
+		tags:          &mock.TagModel{SnippetModel: snippets},
+		templateCache: templateCache,
+		tokens:        &mock.TokenModel{},
+		userss:        users,
+	}
+}
+
+// testServer is an HTTPS test server for app.routes(), with a client which
+// keeps cookies and doesn't follow redirects.
+type testServer struct {
+	*httptest.Server
+}
+
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	ts := httptest.NewTLSServer(h)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	t.Cleanup(ts.Close)
+	return &testServer{ts}
+}
+
+// do sends a request to the server and returns the response with its body.
+func (ts *testServer) do(t *testing.T, method, path string, body io.Reader, header http.Header) (*http.Response, string) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, ts.URL+path, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	b, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs, string(b)
+}
+
+func (ts *testServer) get(t *testing.T, path string) (*http.Response, string) {
+	t.Helper()
+	return ts.do(t, http.MethodGet, path, nil, nil)
+}
+
+// postForm posts form to path. Unless the form has a csrf_token already, a
+// valid one is fetched from the login page first.
+func (ts *testServer) postForm(t *testing.T, path string, form url.Values) (*http.Response, string) {
+	t.Helper()
+
+	if _, ok := form["csrf_token"]; !ok {
+		form = maps.Clone(form)
+		form.Set("csrf_token", ts.csrfToken(t))
+	}
+	header := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	return ts.do(t, http.MethodPost, path, strings.NewReader(form.Encode()), header)
+}
+
+var csrfTokenRX = regexp.MustCompile(`<input type='hidden' name='csrf_token' value='(.+?)'>`)
+
+// csrfToken returns the CSRF token of a form on the login page, which also
+// sets the CSRF cookie it belongs to.
+func (ts *testServer) csrfToken(t *testing.T) string {
+	t.Helper()
+
+	_, body := ts.get(t, "/user/login")
+	matches := csrfTokenRX.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatal("no CSRF token found in the login page")
+	}
+	return html.UnescapeString(matches[1])
+}
+
+// signupAndLogin creates a user with the mock store and logs the client in
+// as them, returning their ID.
+func (ts *testServer) signupAndLogin(t *testing.T, app *application, name, email string) int {
+	t.Helper()
+
+	if err := app.userss.Insert(name, email, "password123"); err != nil {
+		t.Fatal(err)
+	}
+	rs, _ := ts.postForm(t, "/user/login", url.Values{"email": {email}, "password": {"password123"}})
+	if rs.StatusCode != http.StatusSeeOther {
+		t.Fatalf("logging in as %s: got status %d", email, rs.StatusCode)
+	}
+
+	id, err := app.userss.Authenticate(email, "password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
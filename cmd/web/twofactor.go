@@ -0,0 +1,361 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"image/png"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"vincellauderes.net/snippetbox/pkg/forms"
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+// totpIssuer names the site in authenticator apps.
+const totpIssuer = "Snippetbox"
+
+// totpPeriod is how many seconds each TOTP code is valid for, the default of
+// authenticator apps.
+const totpPeriod = 30
+
+// pendingLoginTimeout is how long a user who got their password right has
+// to enter their two-factor code before they must log in again.
+const pendingLoginTimeout = 5 * time.Minute
+
+// startLogin logs in a user whose password checked out. Users with
+// two-factor authentication on are only remembered as pending, and are sent
+// on to enter a code.
+func (app *application) startLogin(w http.ResponseWriter, r *http.Request, id int) {
+	secret, err := app.userss.TOTPSecret(id)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	if secret == "" {
+		app.sessions.Put(r, "userID", id)
+		http.Redirect(w, r, "/snippets/create", http.StatusSeeOther)
+		return
+	}
+
+	app.sessions.Put(r, "pendingUserID", id)
+	// Times can't be stored in the session as they are, so the start of
+	// the login is kept as a Unix timestamp.
+	app.sessions.Put(r, "pendingAt", int(time.Now().Unix()))
+	http.Redirect(w, r, "/user/login/totp", http.StatusSeeOther)
+}
+
+// pendingUserID returns the ID of the user waiting to enter their two-factor
+// code, or 0 if there's none or they took too long.
+func (app *application) pendingUserID(r *http.Request) int {
+	id := app.sessions.GetInt(r, "pendingUserID")
+	if id == 0 {
+		return 0
+	}
+	if time.Since(time.Unix(int64(app.sessions.GetInt(r, "pendingAt")), 0)) > pendingLoginTimeout {
+		app.sessions.Remove(r, "pendingUserID")
+		app.sessions.Remove(r, "pendingAt")
+		return 0
+	}
+	return id
+}
+
+func (app *application) loginTOTPForm(w http.ResponseWriter, r *http.Request) {
+	if app.pendingUserID(r) == 0 {
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	app.render(w, r, "totp.page.tmpl", &templateData{
+		Form: forms.New(nil),
+	})
+}
+
+// loginTOTP is the second login step. It takes either a code from the user's
+// authenticator app or one of their recovery codes.
+func (app *application) loginTOTP(w http.ResponseWriter, r *http.Request) {
+	id := app.pendingUserID(r)
+	if id == 0 {
+		app.sessions.Put(r, "flash", "Your login timed out. Please log in again.")
+		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("code")
+	if !form.Valid() {
+		app.render(w, r, "totp.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	// Codes are short, so guesses are throttled per user as well as per
+	// client, just like passwords, and count as failed until they check out.
+	userKey := "user:" + strconv.Itoa(id)
+	ipKey := app.clientIP(r)
+	okUser, waitUser := app.loginCodes.Allow(userKey)
+	okIP, waitIP := app.loginIPs.Allow(ipKey)
+	if !okUser || !okIP {
+		if okUser {
+			app.loginCodes.Refund(userKey)
+		}
+		if okIP {
+			app.loginIPs.Refund(ipKey)
+		}
+		wait := max(waitUser, waitIP)
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		form.Errors.Add("code", fmt.Sprintf("Too many wrong codes. Please try again in %s.", humanDuration(wait)))
+		app.renderStatus(w, r, http.StatusTooManyRequests, "totp.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	ok, recovery, err := app.checkSecondFactor(id, form.Get("code"))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	if !ok {
+		form.Errors.Add("code", "This code is incorrect")
+		app.render(w, r, "totp.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	app.loginCodes.Reset(userKey)
+	app.loginIPs.Refund(ipKey)
+	app.sessions.Remove(r, "pendingUserID")
+	app.sessions.Remove(r, "pendingAt")
+	app.sessions.Put(r, "userID", id)
+	if recovery {
+		app.sessions.Put(r, "flash", "You logged in with a recovery code, which won't work again.")
+	}
+
+	http.Redirect(w, r, "/snippets/create", http.StatusSeeOther)
+}
+
+// checkSecondFactor reports whether code is the user's current TOTP code or
+// one of their unused recovery codes, and whether it was a recovery code.
+// TOTP codes are all digits, which recovery codes never are. A TOTP code is
+// refused if the user already logged in with it, or with a later one.
+func (app *application) checkSecondFactor(id int, code string) (ok, recovery bool, err error) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if strings.Trim(code, "0123456789") == "" {
+		secret, err := app.userss.TOTPSecret(id)
+		if err != nil {
+			return false, false, err
+		}
+		step, ok := totpStep(code, secret, time.Now())
+		if !ok {
+			return false, false, nil
+		}
+		ok, err = app.userss.UseTOTPStep(id, step)
+		return ok, false, err
+	}
+
+	ok, err = app.userss.UseRecoveryCode(id, code)
+	return ok, true, err
+}
+
+// totpStep returns the time step, the Unix time divided by totpPeriod, which
+// code is the TOTP code for. Like totp.Validate, it allows for clocks one
+// step out either way.
+func totpStep(code, secret string, now time.Time) (int64, bool) {
+	current := now.Unix() / totpPeriod
+	for step := current - 1; step <= current+1; step++ {
+		ok, err := totp.ValidateCustom(code, secret, time.Unix(step*totpPeriod, 0), totp.ValidateOpts{
+			Period:    totpPeriod,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err == nil && ok {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+func (app *application) twoFactorForm(w http.ResponseWriter, r *http.Request) {
+	app.renderTwoFactor(w, r, &templateData{
+		Form: forms.New(nil),
+		// Fresh recovery codes are passed along from enableTwoFactor through
+		// the session, since this is the only time they're shown.
+		RecoveryCodes: strings.Fields(app.sessions.PopString(r, "recoveryCodes")),
+	})
+}
+
+// renderTwoFactor renders the two-factor settings page. Until the user turns
+// two-factor authentication on, it shows a QR code for a new secret, which
+// is kept in the session so reloading the page doesn't change it.
+func (app *application) renderTwoFactor(w http.ResponseWriter, r *http.Request, td *templateData) {
+	user := app.authenticatedUser(r)
+	if user.TOTPEnabled {
+		app.render(w, r, "twofactor.page.tmpl", td)
+		return
+	}
+
+	key, err := app.pendingTOTPKey(r, user)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	img, err := key.Image(200, 200)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	td.TOTPSecret = key.Secret()
+	td.TOTPQRCode = template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()))
+	app.render(w, r, "twofactor.page.tmpl", td)
+}
+
+// pendingTOTPKey returns the TOTP key the user is setting up, generating one
+// the first time.
+func (app *application) pendingTOTPKey(r *http.Request, user *models.User) (*otp.Key, error) {
+	if url := app.sessions.GetString(r, "totpURL"); url != "" {
+		return otp.NewKeyFromURL(url)
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      totpIssuer,
+		AccountName: user.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	app.sessions.Put(r, "totpURL", key.URL())
+	return key, nil
+}
+
+// enableTwoFactor turns on two-factor authentication once the user proves
+// their authenticator app works by entering a code from it.
+func (app *application) enableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	user := app.authenticatedUser(r)
+	if user.TOTPEnabled {
+		http.Redirect(w, r, "/user/2fa", http.StatusSeeOther)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("code")
+
+	url := app.sessions.GetString(r, "totpURL")
+	if url == "" {
+		// The session has lost the secret the QR code was made from, so
+		// start over with a new one.
+		form.Errors.Add("code", "Your setup expired. Please scan the new QR code.")
+	}
+	if !form.Valid() {
+		app.renderTwoFactor(w, r, &templateData{Form: form})
+		return
+	}
+
+	key, err := otp.NewKeyFromURL(url)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	step, ok := totpStep(strings.ReplaceAll(form.Get("code"), " ", ""), key.Secret(), time.Now())
+	if !ok {
+		form.Errors.Add("code", "This code is incorrect")
+		app.renderTwoFactor(w, r, &templateData{Form: form})
+		return
+	}
+
+	codes, err := models.NewRecoveryCodes(models.RecoveryCodeCount)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	err = app.userss.EnableTOTP(user.ID, key.Secret(), codes)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// The code just entered mustn't work again for logging in.
+	_, err = app.userss.UseTOTPStep(user.ID, step)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessions.Remove(r, "totpURL")
+	app.sessions.Put(r, "flash", "Two-factor authentication is on. Save your recovery codes now, they won't be shown again.")
+	app.sessions.Put(r, "recoveryCodes", strings.Join(codes, " "))
+	http.Redirect(w, r, "/user/2fa", http.StatusSeeOther)
+}
+
+// disableTwoFactor turns off two-factor authentication. The user has to enter
+// their password again, so a session left open somewhere isn't enough.
+func (app *application) disableTwoFactor(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	user := app.authenticatedUser(r)
+	form := forms.New(r.PostForm)
+	form.Required("password")
+	if !form.Valid() {
+		app.renderTwoFactor(w, r, &templateData{Form: form})
+		return
+	}
+
+	// Wrong passwords count towards the same throttle as failed logins,
+	// and every attempt counts as wrong until the password checks out.
+	emailKey := strings.ToLower(user.Email)
+	if ok, wait := app.loginEmails.Allow(emailKey); !ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		form.Errors.Add("password", fmt.Sprintf("Too many wrong passwords. Please try again in %s.", humanDuration(wait)))
+		app.renderStatus(w, r, http.StatusTooManyRequests, "twofactor.page.tmpl", &templateData{Form: form})
+		return
+	}
+
+	_, err = app.userss.Authenticate(user.Email, form.Get("password"))
+	if err == models.ErrInvalidCredentials {
+		form.Errors.Add("password", "Password is incorrect")
+		app.renderTwoFactor(w, r, &templateData{Form: form})
+		return
+	} else if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.loginEmails.Reset(emailKey)
+
+	err = app.userss.DisableTOTP(user.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessions.Put(r, "flash", "Two-factor authentication is off.")
+	http.Redirect(w, r, "/user/profile", http.StatusSeeOther)
+}
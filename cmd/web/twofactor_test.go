@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+func TestLoginTOTP(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	id := ts.signupAndLogin(t, app, "Alice", "alice@example.com")
+
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: totpIssuer, AccountName: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := models.NewRecoveryCodes(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.userss.EnableTOTP(id, key.Secret(), codes); err != nil {
+		t.Fatal(err)
+	}
+
+	// enterCode logs in afresh with the password and then enters code.
+	enterCode := func(t *testing.T, code string) (*http.Response, string) {
+		t.Helper()
+
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts.Client().Jar = jar
+
+		rs, _ := ts.postForm(t, "/user/login", url.Values{"email": {"alice@example.com"}, "password": {"password123"}})
+		if rs.StatusCode != http.StatusSeeOther || rs.Header.Get("Location") != "/user/login/totp" {
+			t.Fatalf("password: got %d to %q", rs.StatusCode, rs.Header.Get("Location"))
+		}
+		return ts.postForm(t, "/user/login/totp", url.Values{"code": {code}})
+	}
+
+	code, err := totp.GenerateCode(key.Secret(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantCode int
+	}{
+		{"TOTP code", code, http.StatusSeeOther},
+		{"Replayed TOTP code", code, http.StatusOK},
+		{"Wrong TOTP code", strings.Repeat("0", 6), http.StatusOK},
+		{"Recovery code", strings.ToUpper(codes[0]), http.StatusSeeOther},
+		{"Used recovery code", codes[0], http.StatusOK},
+		{"Other recovery code", strings.ReplaceAll(codes[1], "-", ""), http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rs, body := enterCode(t, tt.code)
+			if rs.StatusCode != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, rs.StatusCode)
+			}
+			if tt.wantCode == http.StatusOK && !strings.Contains(body, "This code is incorrect") {
+				t.Error("want the code to be rejected")
+			}
+		})
+	}
+}
+
+func TestTOTPStep(t *testing.T) {
+	key, err := totp.Generate(totp.GenerateOpts{Issuer: totpIssuer, AccountName: "alice@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	step := now.Unix() / totpPeriod
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code, err := totp.GenerateCode(key.Secret(), time.Unix((step+offset)*totpPeriod, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := totpStep(code, key.Secret(), now); !ok || got != step+offset {
+			t.Errorf("code for step %+d: got %d, %t; want %d", offset, got, ok, step+offset)
+		}
+	}
+
+	old, err := totp.GenerateCode(key.Secret(), time.Unix((step-2)*totpPeriod, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := totpStep(old, key.Secret(), now); ok && got == step-2 {
+		t.Error("code two steps old was accepted")
+	}
+}
@@ -0,0 +1,312 @@
+// Package diff computes line-based unified diffs between two texts.
+package diff
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Op says what happened to a line between the old and the new text.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// String returns the name of the operation, like "insert".
+func (o Op) String() string {
+	switch o {
+	case Delete:
+		return "delete"
+	case Insert:
+		return "insert"
+	}
+	return "equal"
+}
+
+// Line is a single line of a diff.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Prefix returns the marker used for the line in unified diff output.
+func (l Line) Prefix() string {
+	switch l.Op {
+	case Delete:
+		return "-"
+	case Insert:
+		return "+"
+	}
+	return " "
+}
+
+// Hunk is a group of changed lines together with their surrounding context.
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []Line
+}
+
+// Header returns the hunk's "@@ -a,b +c,d @@" range line.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// MaxLines and MaxBytes limit the size of the texts Unified compares, old and
+// new together. The time a diff takes grows with the size of the texts times
+// the number of changes, so larger texts are refused with ErrTooLarge.
+const (
+	MaxLines = 10000
+	MaxBytes = 2 << 20
+)
+
+// ErrTooLarge is returned by Unified for texts over MaxLines or MaxBytes.
+var ErrTooLarge = errors.New("diff: texts too large to compare")
+
+// Lines compares the old and new texts line by line and returns every line of
+// both, marked as kept, deleted or inserted. Within each run of changes the
+// deleted lines come before the inserted ones.
+func Lines(old, new string) []Line {
+	a, b := split(old), split(new)
+
+	lines := make([]Line, 0, len(a)+len(b))
+	lines = myers(a, b, lines)
+
+	// Myers' algorithm may interleave deletions and insertions, so move
+	// the deletions of each run of changes to its front, like diff does.
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			i++
+			continue
+		}
+		j := i
+		for j < len(lines) && lines[j].Op != Equal {
+			j++
+		}
+		sort.SliceStable(lines[i:j], func(x, y int) bool {
+			return lines[i+x].Op == Delete && lines[i+y].Op == Insert
+		})
+		i = j
+	}
+
+	return lines
+}
+
+// Unified compares the old and new texts and groups the changes into hunks
+// with up to context unchanged lines around them, like diff -u does. It
+// returns nil when the texts are identical.
+func Unified(old, new string, context int) ([]Hunk, error) {
+	if len(old)+len(new) > MaxBytes || strings.Count(old, "\n")+strings.Count(new, "\n") > MaxLines {
+		return nil, ErrTooLarge
+	}
+
+	lines := Lines(old, new)
+
+	// oldNo[i] and newNo[i] hold the line numbers lines[i] starts at in the
+	// old and new texts respectively.
+	oldNo := make([]int, len(lines)+1)
+	newNo := make([]int, len(lines)+1)
+	oldNo[0], newNo[0] = 1, 1
+	for i, l := range lines {
+		oldNo[i+1], newNo[i+1] = oldNo[i], newNo[i]
+		if l.Op != Insert {
+			oldNo[i+1]++
+		}
+		if l.Op != Delete {
+			newNo[i+1]++
+		}
+	}
+
+	var hunks []Hunk
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			i++
+			continue
+		}
+
+		// Extend the hunk over any further changes close enough that their
+		// context would overlap.
+		last := i
+		for j := i + 1; j < len(lines); j++ {
+			if lines[j].Op == Equal {
+				continue
+			}
+			if j-last-1 > 2*context {
+				break
+			}
+			last = j
+		}
+
+		start := max(i-context, 0)
+		stop := min(last+context+1, len(lines))
+		h := Hunk{
+			OldStart: oldNo[start],
+			OldLines: oldNo[stop] - oldNo[start],
+			NewStart: newNo[start],
+			NewLines: newNo[stop] - newNo[start],
+			Lines:    lines[start:stop],
+		}
+		// diff -u reports the line before an empty range.
+		if h.OldLines == 0 {
+			h.OldStart--
+		}
+		if h.NewLines == 0 {
+			h.NewStart--
+		}
+		hunks = append(hunks, h)
+
+		i = stop
+	}
+
+	return hunks, nil
+}
+
+// myers appends the diff of a and b to lines, using Myers' O(ND) algorithm
+// in linear space: it finds where an optimal edit path crosses the middle,
+// then diffs the two halves on either side of that point.
+func myers(a, b []string, lines []Line) []Line {
+	// Lines shared at the start and end are never part of the changes.
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		lines = append(lines, Line{Equal, a[pre]})
+		pre++
+	}
+	a, b = a[pre:], b[pre:]
+
+	suf := 0
+	for suf < len(a) && suf < len(b) && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	common := a[len(a)-suf:]
+	a, b = a[:len(a)-suf], b[:len(b)-suf]
+
+	if len(a) == 0 || len(b) == 0 {
+		for _, t := range a {
+			lines = append(lines, Line{Delete, t})
+		}
+		for _, t := range b {
+			lines = append(lines, Line{Insert, t})
+		}
+	} else if x, y, ok := middle(a, b); ok {
+		lines = myers(a[:x], b[:y], lines)
+		lines = myers(a[x:], b[y:], lines)
+	} else {
+		// Nothing in common at all.
+		for _, t := range a {
+			lines = append(lines, Line{Delete, t})
+		}
+		for _, t := range b {
+			lines = append(lines, Line{Insert, t})
+		}
+	}
+
+	for _, t := range common {
+		lines = append(lines, Line{Equal, t})
+	}
+	return lines
+}
+
+// middle searches for an optimal edit path from the start and the end of a
+// and b at the same time, and returns the point where the two searches meet.
+// It reports false if a and b have no line in common. Both must be
+// non-empty, and must differ in their first and last lines.
+func middle(a, b []string) (x, y int, ok bool) {
+	n, m := len(a), len(b)
+	maxD := (n + m + 1) / 2
+	offset := maxD + 1
+
+	// fwd[offset+k] is the furthest x reached on diagonal k = x-y going
+	// forwards, and bwd[offset+k] the furthest distance from the end
+	// reached going backwards, or -1 where nothing was reached yet.
+	fwd := make([]int, 2*offset+1)
+	bwd := make([]int, 2*offset+1)
+	for i := range fwd {
+		fwd[i], bwd[i] = -1, -1
+	}
+	fwd[offset+1], bwd[offset+1] = 0, 0
+
+	delta := n - m
+	// With an odd delta the searches can only meet on a forward step.
+	front := delta%2 != 0
+
+	// Diagonals which ran off the edge of the edit graph are skipped from
+	// then on, by narrowing the range of k searched.
+	kStart1, kEnd1, kStart2, kEnd2 := 0, 0, 0, 0
+	for d := 0; d < maxD; d++ {
+		for k := -d + kStart1; k <= d-kEnd1; k += 2 {
+			var x1 int
+			if k == -d || (k != d && fwd[offset+k-1] < fwd[offset+k+1]) {
+				x1 = fwd[offset+k+1]
+			} else {
+				x1 = fwd[offset+k-1] + 1
+			}
+			y1 := x1 - k
+			for x1 < n && y1 < m && a[x1] == b[y1] {
+				x1++
+				y1++
+			}
+			fwd[offset+k] = x1
+
+			switch {
+			case x1 > n:
+				kEnd1 += 2
+			case y1 > m:
+				kStart1 += 2
+			case front:
+				k2 := offset + delta - k
+				if k2 >= 0 && k2 < len(bwd) && bwd[k2] != -1 && x1 >= n-bwd[k2] {
+					return x1, y1, true
+				}
+			}
+		}
+
+		for k := -d + kStart2; k <= d-kEnd2; k += 2 {
+			var x2 int
+			if k == -d || (k != d && bwd[offset+k-1] < bwd[offset+k+1]) {
+				x2 = bwd[offset+k+1]
+			} else {
+				x2 = bwd[offset+k-1] + 1
+			}
+			y2 := x2 - k
+			for x2 < n && y2 < m && a[n-x2-1] == b[m-y2-1] {
+				x2++
+				y2++
+			}
+			bwd[offset+k] = x2
+
+			switch {
+			case x2 > n:
+				kEnd2 += 2
+			case y2 > m:
+				kStart2 += 2
+			case !front:
+				k1 := offset + delta - k
+				if k1 >= 0 && k1 < len(fwd) && fwd[k1] != -1 {
+					x1 := fwd[k1]
+					y1 := x1 - (k1 - offset)
+					if x1 >= n-x2 {
+						return x1, y1, true
+					}
+				}
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// split breaks text into lines, normalising Windows line endings which
+// browsers submit for textarea fields.
+func split(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,155 @@
+package diff
+
+import (
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"Identical", "a\nb\n", "a\nb\n", " a| b"},
+		{"Empty", "", "", ""},
+		{"Insert", "a\nc", "a\nb\nc", " a|+b| c"},
+		{"Delete", "a\nb\nc", "a\nc", " a|-b| c"},
+		{"Replace", "a\nb\nc", "a\nx\nc", " a|-b|+x| c"},
+		{"All new", "", "a\nb", "+a|+b"},
+		{"All gone", "a\nb", "", "-a|-b"},
+		{"Deletions first", "a\nb", "x\ny", "-a|-b|+x|+y"},
+		{"Windows line endings", "a\r\nb\r\n", "a\nb", " a| b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := format(Lines(tt.old, tt.new)); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestLinesMinimal checks on random texts that Lines reproduces both texts
+// and keeps as many lines as the longest common subsequence.
+func TestLinesMinimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	random := func() []string {
+		lines := make([]string, rng.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 2000; i++ {
+		a, b := random(), random()
+		lines := Lines(strings.Join(a, "\n"), strings.Join(b, "\n"))
+
+		var gotA, gotB []string
+		kept := 0
+		for _, l := range lines {
+			if l.Op != Insert {
+				gotA = append(gotA, l.Text)
+			}
+			if l.Op != Delete {
+				gotB = append(gotB, l.Text)
+			}
+			if l.Op == Equal {
+				kept++
+			}
+		}
+		if strings.Join(gotA, "\n") != strings.Join(a, "\n") || strings.Join(gotB, "\n") != strings.Join(b, "\n") {
+			t.Fatalf("diff of %q and %q doesn't reproduce them: %q", a, b, format(lines))
+		}
+		if want := lcsLength(a, b); kept != want {
+			t.Fatalf("diff of %q and %q keeps %d lines; want %d", a, b, kept, want)
+		}
+	}
+}
+
+func TestUnified(t *testing.T) {
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	new := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n"
+
+	hunks, err := Unified(old, new, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hunks) != 2 {
+		t.Fatalf("got %d hunks; want 2", len(hunks))
+	}
+	for i, want := range []string{"@@ -1,5 +1,5 @@", "@@ -10,3 +10,3 @@"} {
+		if got := hunks[i].Header(); got != want {
+			t.Errorf("hunk %d header is %q; want %q", i, got, want)
+		}
+	}
+
+	hunks, err = Unified(old, old, 3)
+	if err != nil || hunks != nil {
+		t.Errorf("identical texts: got %v, %v; want no hunks", hunks, err)
+	}
+}
+
+func TestUnifiedTooLarge(t *testing.T) {
+	many := strings.Repeat("x\n", MaxLines)
+	if _, err := Unified(many, "y\n", 3); err != ErrTooLarge {
+		t.Errorf("too many lines: got %v; want ErrTooLarge", err)
+	}
+
+	long := strings.Repeat("x", MaxBytes)
+	if _, err := Unified(long, "y", 3); err != ErrTooLarge {
+		t.Errorf("too many bytes: got %v; want ErrTooLarge", err)
+	}
+}
+
+// TestUnifiedWorstCase diffs texts of the largest size allowed which have
+// nothing in common, the slowest case for the algorithm.
+func TestUnifiedWorstCase(t *testing.T) {
+	var a, b strings.Builder
+	for i := 0; i < MaxLines/2; i++ {
+		a.WriteString("old " + strconv.Itoa(i) + "\n")
+		b.WriteString("new " + strconv.Itoa(i) + "\n")
+	}
+
+	start := time.Now()
+	hunks, err := Unified(a.String(), b.String(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hunks) != 1 || len(hunks[0].Lines) != MaxLines {
+		t.Errorf("got %d hunks; want one of %d lines", len(hunks), MaxLines)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("took %s", elapsed)
+	}
+}
+
+func format(lines []Line) string {
+	parts := make([]string, len(lines))
+	for i, l := range lines {
+		parts[i] = l.Prefix() + l.Text
+	}
+	return strings.Join(parts, "|")
+}
+
+// lcsLength returns the length of the longest common subsequence of a and b.
+func lcsLength(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for i := range a {
+		cur := make([]int, len(b)+1)
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
@@ -40,6 +40,14 @@ func (f *Form) MaxLength(field string, d int) {
 	}
 }
 
+// MaxBytes is like MaxLength, but counts the bytes of the field's UTF-8
+// encoding rather than its characters, for limits such as bcrypt's.
+func (f *Form) MaxBytes(field string, d int) {
+	if len(f.Get(field)) > d {
+		f.Errors.Add(field, fmt.Sprintf("This field is too long (maximum is %d bytes)", d))
+	}
+}
+
 func (f *Form) PermittedValues(field string, opts ...string) {
 	value := f.Get(field)
 	if value == "" {
@@ -77,6 +85,37 @@ func (f *Form) MatchesPattern(field string, pattern *regexp.Regexp) {
 	}
 }
 
+// List splits a comma-separated field into its items, trimming whitespace
+// and dropping empty and repeated items.
+func (f *Form) List(field string) []string {
+	items := []string{}
+	seen := map[string]bool{}
+	for _, item := range strings.Split(f.Get(field), ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		items = append(items, item)
+	}
+	return items
+}
+
+func (f *Form) MaxItems(field string, d int) {
+	if len(f.List(field)) > d {
+		f.Errors.Add(field, fmt.Sprintf("This field has too many items (maximum is %d)", d))
+	}
+}
+
+func (f *Form) ItemsMatchPattern(field string, pattern *regexp.Regexp) {
+	for _, item := range f.List(field) {
+		if !pattern.MatchString(item) {
+			f.Errors.Add(field, fmt.Sprintf("%q is invalid", item))
+			return
+		}
+	}
+}
+
 func (f *Form) Valid() bool {
 	return len(f.Errors) == 0
 }
@@ -0,0 +1,282 @@
+// Package highlight renders snippet content as syntax highlighted HTML and
+// guesses which language a snippet is written in.
+package highlight
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// Language is one of the languages a snippet can be highlighted as. Value is
+// what gets stored with the snippet and doubles as the name of the lexer, and
+// Ext is the file extension used when the snippet is downloaded.
+type Language struct {
+	Value string
+	Label string
+	Ext   string
+}
+
+// Languages lists the languages offered when creating a snippet. Markdown
+// snippets are shown rendered, with the highlighted source alongside.
+var Languages = []Language{
+	{"bash", "Bash", ".sh"},
+	{"c", "C", ".c"},
+	{"cpp", "C++", ".cpp"},
+	{"csharp", "C#", ".cs"},
+	{"css", "CSS", ".css"},
+	{"go", "Go", ".go"},
+	{"html", "HTML", ".html"},
+	{"java", "Java", ".java"},
+	{"javascript", "JavaScript", ".js"},
+	{"json", "JSON", ".json"},
+	{"kotlin", "Kotlin", ".kt"},
+	{"markdown", "Markdown", ".md"},
+	{"php", "PHP", ".php"},
+	{"python", "Python", ".py"},
+	{"ruby", "Ruby", ".rb"},
+	{"rust", "Rust", ".rs"},
+	{"sql", "SQL", ".sql"},
+	{"typescript", "TypeScript", ".ts"},
+	{"xml", "XML", ".xml"},
+	{"yaml", "YAML", ".yaml"},
+}
+
+// Values returns the Value of every supported language.
+func Values() []string {
+	values := make([]string, len(Languages))
+	for i, l := range Languages {
+		values[i] = l.Value
+	}
+	return values
+}
+
+// Label returns the display name of a language value, or "Plain text" for
+// the empty value and unknown ones.
+func Label(value string) string {
+	for _, l := range Languages {
+		if l.Value == value {
+			return l.Label
+		}
+	}
+	return "Plain text"
+}
+
+// Ext returns the file extension for a language value, or ".txt" for the
+// empty value and unknown ones.
+func Ext(value string) string {
+	for _, l := range Languages {
+		if l.Value == value {
+			return l.Ext
+		}
+	}
+	return ".txt"
+}
+
+// Detect guesses the language of some content. It returns an empty string,
+// meaning plain text, when it can't tell or the guess isn't a supported
+// language.
+//
+// Chroma's own analysers only recognise a handful of languages, so they are
+// the last resort after a shebang line, the first character of the content
+// and the telltale lines in rules.
+func Detect(content string) string {
+	content = strings.TrimSpace(strings.ReplaceAll(content, "\r\n", "\n"))
+	if content == "" {
+		return ""
+	}
+
+	if language := detectShebang(content); language != "" {
+		return language
+	}
+	if language := detectMarkup(content); language != "" {
+		return language
+	}
+	if (content[0] == '{' || content[0] == '[') && json.Valid([]byte(content)) {
+		return "json"
+	}
+
+	for _, r := range rules {
+		if r.match(content) {
+			return r.language
+		}
+	}
+
+	return analyse(content)
+}
+
+// shebangs maps the interpreters named on a "#!" line to languages.
+var shebangs = map[string]string{
+	"bash":    "bash",
+	"sh":      "bash",
+	"zsh":     "bash",
+	"node":    "javascript",
+	"php":     "php",
+	"python":  "python",
+	"python3": "python",
+	"ruby":    "ruby",
+}
+
+// detectShebang returns the language of a script starting with a "#!" line,
+// like "#!/bin/sh" or "#!/usr/bin/env python3".
+func detectShebang(content string) string {
+	if !strings.HasPrefix(content, "#!") {
+		return ""
+	}
+	line, _, _ := strings.Cut(content[2:], "\n")
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	interpreter := path.Base(fields[0])
+	if interpreter == "env" && len(fields) > 1 {
+		interpreter = fields[1]
+	}
+	return shebangs[interpreter]
+}
+
+// htmlTagRX matches the opening of common HTML elements.
+var htmlTagRX = regexp.MustCompile(`(?i)^<(!doctype html|html|head|body|div|span|p|a|script|style|table|ul|ol|h[1-6]|form|section|main|nav|header|footer)[\s>]`)
+
+// xmlTagRX matches the opening of any other element.
+var xmlTagRX = regexp.MustCompile(`^<[A-Za-z][\w:.-]*[\s/>]`)
+
+// detectMarkup recognises content starting with a tag: PHP, HTML or XML.
+func detectMarkup(content string) string {
+	switch {
+	case content[0] != '<':
+		return ""
+	case strings.HasPrefix(content, "<?php"):
+		return "php"
+	case htmlTagRX.MatchString(content):
+		return "html"
+	case strings.HasPrefix(content, "<?xml"), xmlTagRX.MatchString(content):
+		return "xml"
+	}
+	return ""
+}
+
+// rule recognises a language by lines which hardly appear in any other.
+type rule struct {
+	language string
+	match    func(content string) bool
+}
+
+// anyLine returns a match function reporting whether any line of the
+// content matches one of the patterns, which are anchored to the start of
+// the line.
+func anyLine(patterns ...string) func(string) bool {
+	rx := regexp.MustCompile(`(?m)^\s*(?:` + strings.Join(patterns, "|") + `)`)
+	return rx.MatchString
+}
+
+// rules are tried in order, so languages whose telltale lines also appear in
+// others, like "import x", come after the more specific ones.
+var rules = []rule{
+	{"go", anyLine(`package \w+\s*$`, `func (?:\([^)]*\) )?\w+\(`, `import \($`)},
+	{"java", anyLine(`package [\w.]+;`, `import java\.`, `public (?:static )?(?:final )?class \w+`, `public static void main\(`)},
+	{"csharp", anyLine(`using System[\w.]*;`, `namespace [\w.]+;`, `Console\.Write`)},
+	{"kotlin", anyLine(`fun \w+\(`, `import kotlin\.`, `val \w+ = `)},
+	{"rust", anyLine(`(?:pub )?fn \w+`, `let mut `, `use std::`, `impl\b`)},
+	{"cpp", anyLine(`#include <(?:iostream|vector|string|map|memory|algorithm)>`, `using namespace std;`, `std::`)},
+	{"c", anyLine(`#include [<"]`, `int main\(`)},
+	{"python", anyLine(`def \w+\(.*\):`, `class \w+(?:\(.*\))?:\s*$`, `(?:from [\w.]+ )?import [\w.]+(?: as \w+)?\s*$`, `if __name__ == `)},
+	{"ruby", anyLine(`require ['"]`, `puts `, `def \w+[!?]?\s*$`, `attr_accessor `)},
+	{"typescript", anyLine(`(?:export )?interface \w+`, `(?:export )?type \w+ = `, `(?:const|let|var) \w+: \w+`, `function \w+\(.*\): \w+`)},
+	{"javascript", anyLine(`(?:const|let|var) \w+\s*=`, `function\s*\w*\s*\(`, `console\.log\(`, `module\.exports`, `import .* from ['"]`, `export (?:default|const|function) `)},
+	{"sql", anyLine(`(?i:select\s.+\sfrom\s)`, `(?i:insert into\s)`, `(?i:update \w+ set\s)`, `(?i:delete from\s)`, `(?i:create (?:table|index|view|unique index)\s)`, `(?i:alter table\s)`, `(?i:drop table\s)`)},
+	{"css", func(content string) bool {
+		return cssBlockRX.MatchString(content) && cssDeclarationRX.MatchString(content)
+	}},
+	{"bash", anyLine(`echo `, `export \w+=`, `if \[`, `fi\s*$`, `done\s*$`, `\w+=\$\(`)},
+	{"yaml", isYAML},
+}
+
+var (
+	cssBlockRX       = regexp.MustCompile(`(?m)^[^{}\n]+\{\s*$`)
+	cssDeclarationRX = regexp.MustCompile(`(?m)^\s*[\w-]+\s*:\s*[^;]+;\s*$`)
+	yamlLineRX       = regexp.MustCompile(`^\s*(?:- )?[\w.-]+:(?:\s.*)?$|^\s*- .+$|^\s*#|^---$`)
+)
+
+// isYAML reports whether content is a YAML document: it starts with "---",
+// or has several lines which are all keys, list items or comments.
+func isYAML(content string) bool {
+	if strings.HasPrefix(content, "---\n") {
+		return true
+	}
+	lines := 0
+	for _, line := range strings.Split(content, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if !yamlLineRX.MatchString(line) {
+			return false
+		}
+		lines++
+	}
+	return lines >= 2
+}
+
+// analyse asks chroma's analysers for a supported language.
+func analyse(content string) string {
+	lexer := lexers.Analyse(content)
+	if lexer == nil {
+		return ""
+	}
+
+	name := lexer.Config().Name
+	for _, l := range Languages {
+		if lx := lexers.Get(l.Value); lx != nil && lx.Config().Name == name {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+// formatter renders CSS classes rather than inline styles, which keeps the
+// output compatible with a strict Content-Security-Policy. The matching
+// stylesheet lives in ui/static/css/highlight.css. Line numbers sit in their
+// own table column, so copying the code doesn't copy them, and each one links
+// to an #L<n> anchor.
+var formatter = html.New(
+	html.WithClasses(true),
+	html.WithLineNumbers(true),
+	html.LineNumbersInTable(true),
+	html.WithLinkableLineNumbers(true, "L"),
+	html.TabWidth(4),
+)
+
+// style is the colour scheme highlight.css was generated from.
+var style = styles.Get("github")
+
+// HTML renders content highlighted as the given language, with numbered and
+// linkable lines. Unknown or empty languages are rendered as plain text.
+func HTML(content, language string) (template.HTML, error) {
+	lexer := lexers.Get(language)
+	if language == "" || lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := formatter.Format(&buf, style, iterator); err != nil {
+		return "", err
+	}
+
+	// The formatter escapes the content itself, so the markup is safe to
+	// include in a template as is.
+	return template.HTML(buf.String()), nil
+}
@@ -0,0 +1,49 @@
+package highlight
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"Go without fmt", "package main\n\nfunc add(a, b int) int {\n\treturn a + b\n}\n", "go"},
+		{"Go method", "func (s *Server) Close() error {\n\treturn nil\n}", "go"},
+		{"Python shebang", "#!/usr/bin/env python\nprint('hi')\n", "python"},
+		{"Python function", "import os\n\ndef main():\n    print(os.getcwd())\n", "python"},
+		{"Bash shebang", "#!/bin/sh\nls -la\n", "bash"},
+		{"Node shebang", "#!/usr/bin/env node\nprocess.exit(0)\n", "javascript"},
+		{"HTML doctype", "<!DOCTYPE html>\n<html>\n<body>Hi</body>\n</html>\n", "html"},
+		{"HTML fragment", "<div class='box'>\n  <p>Hi</p>\n</div>", "html"},
+		{"XML", "<?xml version=\"1.0\"?>\n<note><to>Tove</to></note>", "xml"},
+		{"PHP", "<?php\necho 'hi';\n", "php"},
+		{"JSON object", "{\"name\": \"snippetbox\", \"tags\": [\"go\"]}", "json"},
+		{"JSON array", "[1, 2, 3]", "json"},
+		{"SQL", "SELECT id, title FROM snippets WHERE expires > now();", "sql"},
+		{"SQL lower case", "create table users (\n  id serial primary key\n);", "sql"},
+		{"Java", "public class Hello {\n    public static void main(String[] args) {}\n}", "java"},
+		{"Rust", "fn main() {\n    let mut x = 1;\n}", "rust"},
+		{"C", "#include <stdio.h>\n\nint main() {\n    return 0;\n}", "c"},
+		{"C++", "#include <iostream>\n\nint main() {\n    std::cout << 1;\n}", "cpp"},
+		{"JavaScript", "const add = (a, b) => a + b;\nconsole.log(add(1, 2));", "javascript"},
+		{"TypeScript", "interface User {\n  name: string;\n}", "typescript"},
+		{"CSS", "body {\n  color: red;\n}\n", "css"},
+		{"Markdown is left to the author", "# Title\n\nSome [link](https://example.com).", ""},
+		{"YAML with a comment", "# comment\nservices:\n  web:\n    image: nginx\n", "yaml"},
+		{"Comment and assignment", "# setup\nx = 1", ""},
+		{"Shell with a comment", "# setup\nexport PATH=$HOME/bin\n", "bash"},
+		{"Python with a comment", "# comment\nimport os\nprint(os.name)\n", "python"},
+		{"YAML", "name: snippetbox\nversion: 1\ntags:\n  - go\n", "yaml"},
+		{"Plain text", "Remember to buy milk.", ""},
+		{"Empty", "  \n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Detect(tt.content); got != tt.want {
+				t.Errorf("got %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
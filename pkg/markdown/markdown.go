@@ -0,0 +1,40 @@
+// Package markdown renders snippets written in Markdown as sanitized HTML.
+package markdown
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+// renderer understands GitHub flavoured Markdown, so tables, strikethrough,
+// task lists and autolinks work alongside headings, lists and code fences.
+// Raw HTML in the source is left out of the output.
+var renderer = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+)
+
+// policy strips anything that could run script or restyle the page, like
+// <script>, event handler attributes and javascript: URLs, from the rendered
+// HTML. It is applied on top of the renderer dropping raw HTML so that a
+// mistake in either one isn't enough to let markup through.
+var policy = func() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	// Keep the language hint goldmark puts on code fences.
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	return p
+}()
+
+// HTML renders Markdown source as sanitized HTML that is safe to include in a
+// template.
+func HTML(src string) (template.HTML, error) {
+	var buf bytes.Buffer
+	if err := renderer.Convert([]byte(src), &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(policy.SanitizeBytes(buf.Bytes())), nil
+}
@@ -0,0 +1,103 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "Script element",
+			src:     "Hello\n\n<script>alert(1)</script>\n",
+			want:    []string{"<p>Hello</p>"},
+			notWant: []string{"<script", "alert(1)"},
+		},
+		{
+			name:    "Inline script element",
+			src:     "Hello <script>alert(1)</script> world",
+			notWant: []string{"<script"},
+		},
+		{
+			name:    "JavaScript link",
+			src:     "[click](javascript:alert(1))",
+			want:    []string{"click"},
+			notWant: []string{"javascript:", "href"},
+		},
+		{
+			name:    "JavaScript link in any case",
+			src:     "[click](JaVaScRiPt:alert(1))",
+			notWant: []string{"javascript:", "JaVaScRiPt:", "href"},
+		},
+		{
+			name:    "JavaScript image",
+			src:     "![pic](javascript:alert(1))",
+			notWant: []string{"javascript:", "src="},
+		},
+		{
+			name:    "JavaScript reference link",
+			src:     "[click][x]\n\n[x]: javascript:alert(1)\n",
+			notWant: []string{"javascript:", "href"},
+		},
+		{
+			name:    "Event handler attribute",
+			src:     "<img src=\"cat.png\" onerror=\"alert(1)\">\n\n<a href=\"/\" onclick=\"alert(1)\">home</a>",
+			notWant: []string{"onerror", "onclick", "alert(1)"},
+		},
+		{
+			name:    "Inline style and iframe",
+			src:     "<iframe src=\"https://example.com\"></iframe>\n\n<p style=\"color:red\">red</p>",
+			notWant: []string{"<iframe", "style="},
+		},
+		{
+			name: "Safe links and images",
+			src:  "[docs](https://example.com/docs) ![cat](https://example.com/cat.png)",
+			want: []string{`<a href="https://example.com/docs" rel="nofollow">docs</a>`, `<img src="https://example.com/cat.png" alt="cat">`},
+		},
+		{
+			name: "Table",
+			src:  "| Name | Count |\n| ---- | ----: |\n| go | 3 |\n",
+			want: []string{"<table>", "<th>Name</th>", "<td>go</td>", "3</td>"},
+		},
+		{
+			name:    "Fenced code",
+			src:     "```go\nif a < b {\n\treturn\n}\n```\n",
+			want:    []string{`<pre><code class="language-go">`, "if a &lt; b {"},
+			notWant: []string{"if a < b"},
+		},
+		{
+			name:    "Fenced code language is checked",
+			src:     "```go\" onclick=\"alert(1)\nx\n```\n",
+			notWant: []string{"onclick"},
+		},
+		{
+			name: "Strikethrough and task list",
+			src:  "~~old~~\n\n- [x] done\n",
+			want: []string{"<del>old</del>", "done"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := HTML(tt.src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.want {
+				if !strings.Contains(string(got), s) {
+					t.Errorf("want %q in %q", s, got)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(string(got), s) {
+					t.Errorf("don't want %q in %q", s, got)
+				}
+			}
+		})
+	}
+}
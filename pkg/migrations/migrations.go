@@ -0,0 +1,313 @@
+// Package migrations holds the versioned database schema for every supported
+// backend and applies it. Each migration is a pair of numbered files, like
+// 0001_create_snippets.up.sql and 0001_create_snippets.down.sql, embedded
+// into the binary from a directory named after the database driver.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed mysql/*.sql postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// Migration is a single numbered schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied, and when.
+type Status struct {
+	Migration
+	Applied *time.Time
+}
+
+// dialect captures the few bits of SQL that differ between backends when
+// maintaining the schema_migrations table.
+type dialect struct {
+	createTable string
+	insert      string
+	delete      string
+}
+
+var dialects = map[string]dialect{
+	"mysql": {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		applied DATETIME NOT NULL)`,
+		insert: `INSERT INTO schema_migrations (version, applied) VALUES (?, UTC_TIMESTAMP())`,
+		delete: `DELETE FROM schema_migrations WHERE version = ?`,
+	},
+	"postgres": {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		applied TIMESTAMPTZ NOT NULL)`,
+		insert: `INSERT INTO schema_migrations (version, applied) VALUES ($1, now())`,
+		delete: `DELETE FROM schema_migrations WHERE version = $1`,
+	},
+	"sqlite": {
+		createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		applied DATETIME NOT NULL)`,
+		insert: `INSERT INTO schema_migrations (version, applied) VALUES (?, datetime('now'))`,
+		delete: `DELETE FROM schema_migrations WHERE version = ?`,
+	},
+}
+
+// Migrator applies the embedded migrations for one driver to a database.
+type Migrator struct {
+	DB     *sql.DB
+	Driver string
+}
+
+// Up applies every pending migration in version order and returns the ones
+// it applied.
+func (m *Migrator) Up() ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := []Migration{}
+	for _, s := range statuses {
+		if s.Applied != nil {
+			continue
+		}
+		if err := m.apply(s.Migration, s.Up, dialects[m.Driver].insert); err != nil {
+			return applied, fmt.Errorf("migration %04d_%s: %w", s.Version, s.Name, err)
+		}
+		applied = append(applied, s.Migration)
+	}
+
+	return applied, nil
+}
+
+// Down rolls back the most recently applied migration. It returns nil if
+// there is nothing left to roll back.
+func (m *Migrator) Down() (*Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		s := statuses[i]
+		if s.Applied == nil {
+			continue
+		}
+		if err := m.apply(s.Migration, s.Down, dialects[m.Driver].delete); err != nil {
+			return nil, fmt.Errorf("migration %04d_%s: %w", s.Version, s.Name, err)
+		}
+		return &s.Migration, nil
+	}
+
+	return nil, nil
+}
+
+// Status lists every known migration along with the time it was applied,
+// which is nil for pending migrations.
+func (m *Migrator) Status() ([]Status, error) {
+	d, ok := dialects[m.Driver]
+	if !ok {
+		return nil, fmt.Errorf("migrations: unsupported driver %q", m.Driver)
+	}
+
+	migrations, err := load(m.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := m.DB.Exec(d.createTable); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.Query("SELECT version, applied FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var t time.Time
+		if err := rows.Scan(&version, &t); err != nil {
+			return nil, err
+		}
+		applied[version] = t
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, len(migrations))
+	for i, mg := range migrations {
+		statuses[i].Migration = mg
+		if t, ok := applied[mg.Version]; ok {
+			statuses[i].Applied = &t
+		}
+	}
+
+	return statuses, nil
+}
+
+// apply runs the statements of one migration script and records the change
+// in schema_migrations, all inside a transaction. Note that MySQL commits
+// implicitly after DDL statements, so there a failed migration may be left
+// partially applied.
+func (m *Migrator) apply(mg Migration, script, record string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	for _, stmt := range split(script) {
+		if _, err := tx.Exec(stmt); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(record, mg.Version); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// load reads the embedded migrations for a driver, sorted by version.
+func load(driver string) ([]Migration, error) {
+	entries, err := fs.ReadDir(files, driver)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, e := range entries {
+		// File names look like 0001_create_snippets.up.sql.
+		base := strings.TrimSuffix(e.Name(), ".sql")
+		base, direction := strings.TrimSuffix(base, path.Ext(base)), path.Ext(base)
+		prefix, name, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil || (direction != ".up" && direction != ".down") {
+			return nil, fmt.Errorf("migrations: malformed file name %s/%s", driver, e.Name())
+		}
+
+		b, err := files.ReadFile(path.Join(driver, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version, Name: name}
+			byVersion[version] = mg
+		}
+		if direction == ".up" {
+			mg.Up = string(b)
+		} else {
+			mg.Down = string(b)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" || mg.Down == "" {
+			return nil, fmt.Errorf("migrations: %s/%04d_%s needs both an up and a down file", driver, mg.Version, mg.Name)
+		}
+		migrations = append(migrations, *mg)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// split breaks a script into individual statements, since not every driver
+// accepts several statements per Exec. A semicolon only ends a statement
+// outside of quotes, comments and BEGIN ... END blocks (as used by triggers).
+// Comments are dropped.
+func split(script string) []string {
+	var stmts []string
+	var b strings.Builder
+	depth := 0
+
+	flush := func() {
+		if s := strings.TrimSpace(b.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		b.Reset()
+	}
+
+	for i := 0; i < len(script); {
+		c := script[i]
+		switch {
+		case strings.HasPrefix(script[i:], "--"):
+			// Keep the newline, so the statement's lines stay apart.
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			i += end
+		case strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				end = len(script) - i - 4
+			}
+			b.WriteByte(' ')
+			i += end + 4
+		case c == '\'' || c == '"' || c == '`':
+			// A doubled quote inside a string simply ends the string and
+			// starts another one straight after it.
+			end := strings.IndexByte(script[i+1:], c)
+			if end < 0 {
+				end = len(script) - i - 2
+			}
+			b.WriteString(script[i : i+end+2])
+			i += end + 2
+		case isWordByte(c):
+			j := i
+			for j < len(script) && isWordByte(script[j]) {
+				j++
+			}
+			// CASE ... END can appear inside a trigger, so count those too.
+			switch strings.ToUpper(script[i:j]) {
+			case "BEGIN", "CASE":
+				depth++
+			case "END":
+				if depth > 0 {
+					depth--
+				}
+			}
+			b.WriteString(script[i:j])
+			i = j
+		case c == ';' && depth == 0:
+			b.WriteByte(c)
+			flush()
+			i++
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+
+	return stmts
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
@@ -0,0 +1,106 @@
+package migrations
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "Statements",
+			script: "CREATE TABLE a (id INTEGER);\n\nCREATE TABLE b (id INTEGER);\n",
+			want:   []string{"CREATE TABLE a (id INTEGER);", "CREATE TABLE b (id INTEGER);"},
+		},
+		{
+			name:   "Several on a line",
+			script: "DROP TABLE a; DROP TABLE b;",
+			want:   []string{"DROP TABLE a;", "DROP TABLE b;"},
+		},
+		{
+			name:   "No final semicolon",
+			script: "DROP TABLE a;\nDROP TABLE b\n",
+			want:   []string{"DROP TABLE a;", "DROP TABLE b"},
+		},
+		{
+			name: "Trigger",
+			script: "CREATE TRIGGER t AFTER INSERT ON a BEGIN\n" +
+				"    INSERT INTO b VALUES (new.id);\n" +
+				"    DELETE FROM c;\n" +
+				"END;\n" +
+				"DROP TABLE d;",
+			want: []string{
+				"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n    INSERT INTO b VALUES (new.id);\n    DELETE FROM c;\nEND;",
+				"DROP TABLE d;",
+			},
+		},
+		{
+			name: "CASE inside a trigger",
+			script: "CREATE TRIGGER t AFTER INSERT ON a BEGIN\n" +
+				"    UPDATE a SET n = CASE WHEN n > 0 THEN n ELSE 0 END;\n" +
+				"    DELETE FROM c;\n" +
+				"END;",
+			want: []string{
+				"CREATE TRIGGER t AFTER INSERT ON a BEGIN\n    UPDATE a SET n = CASE WHEN n > 0 THEN n ELSE 0 END;\n    DELETE FROM c;\nEND;",
+			},
+		},
+		{
+			name:   "Keyword inside a name",
+			script: "UPDATE a SET ended = 1;\nUPDATE a SET begins = 2;",
+			want:   []string{"UPDATE a SET ended = 1;", "UPDATE a SET begins = 2;"},
+		},
+		{
+			name:   "Semicolon in a string",
+			script: "INSERT INTO a VALUES ('x; y');\nINSERT INTO a VALUES ('z;\n');",
+			want:   []string{"INSERT INTO a VALUES ('x; y');", "INSERT INTO a VALUES ('z;\n');"},
+		},
+		{
+			name:   "Escaped quote in a string",
+			script: "INSERT INTO a VALUES ('it''s; fine');",
+			want:   []string{"INSERT INTO a VALUES ('it''s; fine');"},
+		},
+		{
+			name:   "Keywords in a string",
+			script: "INSERT INTO a VALUES ('BEGIN');\nDROP TABLE b;",
+			want:   []string{"INSERT INTO a VALUES ('BEGIN');", "DROP TABLE b;"},
+		},
+		{
+			name:   "Quoted identifiers",
+			script: "CREATE TABLE \"a;b\" (id INTEGER);\nCREATE TABLE `c;d` (id INTEGER);",
+			want:   []string{"CREATE TABLE \"a;b\" (id INTEGER);", "CREATE TABLE `c;d` (id INTEGER);"},
+		},
+		{
+			name:   "Line comments",
+			script: "-- Create a; then b.\nCREATE TABLE a (\n    id INTEGER -- the key; unique\n);\n-- done;\n",
+			want:   []string{"CREATE TABLE a (\n    id INTEGER \n);"},
+		},
+		{
+			name:   "Block comments",
+			script: "/* a; b */ DROP TABLE a;/* c;\nd */DROP TABLE b;",
+			want:   []string{"DROP TABLE a;", "DROP TABLE b;"},
+		},
+		{
+			name:   "Comment markers in a string",
+			script: "INSERT INTO a VALUES ('-- x; /* y */');",
+			want:   []string{"INSERT INTO a VALUES ('-- x; /* y */');"},
+		},
+		{
+			name:   "Only comments",
+			script: "-- nothing to do here;\n/* or here; */\n",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := split(tt.script)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
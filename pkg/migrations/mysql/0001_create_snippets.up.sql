@@ -0,0 +1,10 @@
+-- IF NOT EXISTS lets installs created from the old sql/database.sql script
+-- adopt the migrations without losing data.
+CREATE TABLE IF NOT EXISTS snippets (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL,
+    INDEX idx_snippets_created (created)
+);
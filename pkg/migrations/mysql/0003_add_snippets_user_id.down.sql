@@ -0,0 +1,3 @@
+ALTER TABLE snippets DROP FOREIGN KEY fk_snippets_user;
+
+ALTER TABLE snippets DROP COLUMN user_id;
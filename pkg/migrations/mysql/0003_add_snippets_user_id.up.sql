@@ -0,0 +1,5 @@
+-- Snippets created before authorship was recorded keep a NULL user_id.
+ALTER TABLE snippets ADD COLUMN user_id INTEGER NULL;
+
+ALTER TABLE snippets ADD CONSTRAINT fk_snippets_user
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;
@@ -0,0 +1 @@
+CREATE FULLTEXT INDEX ft_snippets_title_content ON snippets(title, content);
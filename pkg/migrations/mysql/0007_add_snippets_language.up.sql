@@ -0,0 +1,3 @@
+-- The language a snippet is written in, used for syntax highlighting. An
+-- empty string means plain text.
+ALTER TABLE snippets ADD COLUMN language VARCHAR(30) NOT NULL DEFAULT '';
@@ -0,0 +1,3 @@
+ALTER TABLE api_tokens DROP COLUMN last_used;
+
+ALTER TABLE api_tokens DROP COLUMN scope;
@@ -0,0 +1,20 @@
+-- The base32 TOTP secret of users who turned on two-factor authentication,
+-- or NULL for everyone else.
+ALTER TABLE users ADD COLUMN totp_secret VARCHAR(64) NULL;
+
+-- The TOTP time step (Unix time / 30) of the last code each user logged in
+-- with. Codes for that step or an earlier one are refused, so a code seen
+-- over someone's shoulder can't be used again while it's still valid.
+ALTER TABLE users ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+-- One-time recovery codes for when the authenticator app is unavailable.
+-- Only SHA-256 hashes of the codes, salted with the user's ID, are stored,
+-- and a code is deleted once used.
+CREATE TABLE recovery_codes (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    user_id INTEGER NOT NULL,
+    code_hash CHAR(64) NOT NULL,
+    CONSTRAINT recovery_codes_uc_user_code UNIQUE (user_id, code_hash),
+    CONSTRAINT fk_recovery_codes_user
+        FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
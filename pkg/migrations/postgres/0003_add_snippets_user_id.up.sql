@@ -0,0 +1,5 @@
+-- Snippets created before authorship was recorded keep a NULL user_id.
+ALTER TABLE snippets ADD COLUMN user_id INTEGER NULL
+    CONSTRAINT fk_snippets_user REFERENCES users(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_snippets_user_id ON snippets(user_id);
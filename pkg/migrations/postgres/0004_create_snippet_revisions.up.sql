@@ -0,0 +1,14 @@
+CREATE TABLE snippet_revisions (
+    id SERIAL PRIMARY KEY,
+    snippet_id INTEGER NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    user_id INTEGER NULL REFERENCES users(id) ON DELETE SET NULL,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_snippet_revisions_snippet_id ON snippet_revisions(snippet_id);
+
+-- Existing snippets start their history with their current contents.
+INSERT INTO snippet_revisions (snippet_id, user_id, title, content, created)
+    SELECT id, user_id, title, content, created FROM snippets;
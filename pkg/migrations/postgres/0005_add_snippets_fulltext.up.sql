@@ -0,0 +1,8 @@
+-- Keep a stemmed search document for every snippet, weighting the title
+-- above the content when ranking results.
+ALTER TABLE snippets ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', title), 'A') ||
+    setweight(to_tsvector('english', content), 'B')
+) STORED;
+
+CREATE INDEX idx_snippets_search ON snippets USING GIN (search);
@@ -0,0 +1,5 @@
+-- Tokens are either read-only or may also write. Existing tokens were made
+-- for pasting, so they keep write access.
+ALTER TABLE api_tokens ADD COLUMN scope VARCHAR(10) NOT NULL DEFAULT 'write';
+
+ALTER TABLE api_tokens ADD COLUMN last_used TIMESTAMPTZ NULL;
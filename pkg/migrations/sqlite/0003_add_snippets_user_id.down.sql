@@ -0,0 +1,20 @@
+-- SQLite can't drop a column that takes part in a foreign key, so rebuild the
+-- table without it.
+DROP INDEX idx_snippets_user_id;
+
+CREATE TABLE snippets_old (
+    id INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT,
+    title VARCHAR(100) NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME NOT NULL
+);
+
+INSERT INTO snippets_old (id, title, content, created, expires)
+    SELECT id, title, content, created, expires FROM snippets;
+
+DROP TABLE snippets;
+
+ALTER TABLE snippets_old RENAME TO snippets;
+
+CREATE INDEX idx_snippets_created ON snippets(created);
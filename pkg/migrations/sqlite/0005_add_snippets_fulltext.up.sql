@@ -0,0 +1,21 @@
+-- An FTS5 index over the snippets table. It stores no copy of the text, so
+-- triggers keep it in step with every change to snippets.
+CREATE VIRTUAL TABLE snippets_fts USING fts5(
+    title, content, content='snippets', content_rowid='id'
+);
+
+CREATE TRIGGER snippets_fts_insert AFTER INSERT ON snippets BEGIN
+    INSERT INTO snippets_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+CREATE TRIGGER snippets_fts_delete AFTER DELETE ON snippets BEGIN
+    INSERT INTO snippets_fts(snippets_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+END;
+
+CREATE TRIGGER snippets_fts_update AFTER UPDATE ON snippets BEGIN
+    INSERT INTO snippets_fts(snippets_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+    INSERT INTO snippets_fts(rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+-- Index the snippets which already exist.
+INSERT INTO snippets_fts(snippets_fts) VALUES ('rebuild');
@@ -0,0 +1,3 @@
+DROP TABLE snippet_tags;
+
+DROP TABLE tags;
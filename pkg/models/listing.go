@@ -0,0 +1,117 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned by ParseCursor for malformed cursors.
+var ErrInvalidCursor = errors.New("models: invalid cursor")
+
+// Cursor marks a position in a snippet listing. Listings are ordered by
+// creation time with the id as a tie-breaker, so the pair identifies exactly
+// where a page starts or ends no matter how many rows are added meanwhile.
+type Cursor struct {
+	Created time.Time
+	ID      int
+}
+
+// String encodes the cursor into an opaque token for use in URLs.
+func (c Cursor) String() string {
+	raw := fmt.Sprintf("%d.%d", c.Created.UnixNano(), c.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// ParseCursor decodes a token created by Cursor.String.
+func ParseCursor(s string) (*Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	created, id, ok := strings.Cut(string(raw), ".")
+	if !ok {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(created, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	n, err := strconv.Atoi(id)
+	if err != nil || n < 1 {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{Created: time.Unix(0, nanos).UTC(), ID: n}, nil
+}
+
+// SnippetFilter selects which unexpired snippets a listing shows and in what
+// order. At most one of After and Before should be set.
+type SnippetFilter struct {
+	// AuthorID limits the listing to one user's snippets when non-zero.
+	AuthorID int
+	// ExpiresWithin limits the listing to snippets expiring within that many
+	// days when non-zero.
+	ExpiresWithin int
+	// Oldest lists the oldest snippets first instead of the newest.
+	Oldest bool
+	// After and Before ask for the page following or preceding a cursor.
+	After  *Cursor
+	Before *Cursor
+	// Limit is the page size.
+	Limit int
+}
+
+// SnippetPage is one page of a snippet listing. Next and Prev are nil when
+// there's no page in that direction.
+type SnippetPage struct {
+	Snippets []*Snippet
+	Next     *Cursor
+	Prev     *Cursor
+}
+
+// Paginate trims rows fetched for a filter into a page. Stores fetch one row
+// more than f.Limit, in the order the page is read in (reversed when paging
+// Before), so the extra row tells whether another page follows.
+func Paginate(rows []*Snippet, f SnippetFilter) *SnippetPage {
+	more := len(rows) > f.Limit
+	if more {
+		rows = rows[:f.Limit]
+	}
+
+	if f.Before != nil {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	p := &SnippetPage{Snippets: rows}
+	if len(rows) == 0 {
+		return p
+	}
+
+	first, last := rows[0], rows[len(rows)-1]
+	switch {
+	case f.Before != nil:
+		// We came backwards from a later page, so there's always a next one.
+		p.Next = &Cursor{last.Created, last.ID}
+		if more {
+			p.Prev = &Cursor{first.Created, first.ID}
+		}
+	case f.After != nil:
+		p.Prev = &Cursor{first.Created, first.ID}
+		if more {
+			p.Next = &Cursor{last.Created, last.ID}
+		}
+	default:
+		if more {
+			p.Next = &Cursor{last.Created, last.ID}
+		}
+	}
+
+	return p
+}
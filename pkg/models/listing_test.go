@@ -0,0 +1,166 @@
+package models
+
+import (
+	"encoding/base64"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseCursor(t *testing.T) {
+	created := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	valid := Cursor{Created: created, ID: 42}
+
+	c, err := ParseCursor(valid.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.Created.Equal(created) || c.ID != 42 {
+		t.Errorf("round trip: want %v; got %v", valid, *c)
+	}
+
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"Empty", ""},
+		{"Not base64", "!!!"},
+		{"Padded base64", base64.URLEncoding.EncodeToString([]byte("1.23"))},
+		{"Standard base64", base64.StdEncoding.EncodeToString([]byte("1709296200000000000.42?"))},
+		{"No separator", encode("1709296200000000000")},
+		{"No ID", encode("1709296200000000000.")},
+		{"No time", encode(".42")},
+		{"Zero ID", encode("1709296200000000000.0")},
+		{"Negative ID", encode("1709296200000000000.-1")},
+		{"Text", encode("yesterday.42")},
+		{"Trailing junk", encode("1709296200000000000.42x")},
+		{"Extra field", encode("1709296200000000000.42.7")},
+		{"Overflow", encode("99999999999999999999.42")},
+		{"Truncated", valid.String()[:len(valid.String())-3]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCursor(tt.cursor)
+			if err != ErrInvalidCursor {
+				t.Errorf("want ErrInvalidCursor; got %v, %v", c, err)
+			}
+		})
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	snippet := func(id int, minutes int) *Snippet {
+		return &Snippet{ID: id, Created: base.Add(time.Duration(minutes) * time.Minute)}
+	}
+	cursor := func(s *Snippet) *Cursor {
+		return &Cursor{Created: s.Created, ID: s.ID}
+	}
+
+	// Newest first, as a store reads them for the default order. Snippets
+	// 3 and 2 were created at the same moment, so their IDs break the tie.
+	s5, s4, s3, s2, s1 := snippet(5, 4), snippet(4, 3), snippet(3, 2), snippet(2, 2), snippet(1, 1)
+
+	tests := []struct {
+		name     string
+		rows     []*Snippet
+		filter   SnippetFilter
+		wantIDs  []int
+		wantNext *Cursor
+		wantPrev *Cursor
+	}{
+		{
+			name:     "First page",
+			rows:     []*Snippet{s5, s4, s3},
+			filter:   SnippetFilter{Limit: 2},
+			wantIDs:  []int{5, 4},
+			wantNext: cursor(s4),
+		},
+		{
+			name:    "Only page",
+			rows:    []*Snippet{s5, s4},
+			filter:  SnippetFilter{Limit: 2},
+			wantIDs: []int{5, 4},
+		},
+		{
+			name:     "Middle page after a cursor",
+			rows:     []*Snippet{s3, s2, s1},
+			filter:   SnippetFilter{Limit: 2, After: cursor(s4)},
+			wantIDs:  []int{3, 2},
+			wantNext: cursor(s2),
+			wantPrev: cursor(s3),
+		},
+		{
+			name:     "End of the list",
+			rows:     []*Snippet{s1},
+			filter:   SnippetFilter{Limit: 2, After: cursor(s2)},
+			wantIDs:  []int{1},
+			wantPrev: cursor(s1),
+		},
+		{
+			name:    "Past the end",
+			rows:    []*Snippet{},
+			filter:  SnippetFilter{Limit: 2, After: cursor(s1)},
+			wantIDs: []int{},
+		},
+		{
+			// Paging backwards, a store reads oldest first from the cursor.
+			name:     "Before a cursor",
+			rows:     []*Snippet{s2, s3, s4},
+			filter:   SnippetFilter{Limit: 2, Before: cursor(s1)},
+			wantIDs:  []int{3, 2},
+			wantNext: cursor(s2),
+			wantPrev: cursor(s3),
+		},
+		{
+			name:     "Back to the first page",
+			rows:     []*Snippet{s4, s5},
+			filter:   SnippetFilter{Limit: 2, Before: cursor(s3)},
+			wantIDs:  []int{5, 4},
+			wantNext: cursor(s4),
+		},
+		{
+			name:     "Tie at the page break",
+			rows:     []*Snippet{s4, s3, s2},
+			filter:   SnippetFilter{Limit: 2, After: cursor(s5)},
+			wantIDs:  []int{4, 3},
+			wantNext: &Cursor{Created: base.Add(2 * time.Minute), ID: 3},
+			wantPrev: cursor(s4),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := Paginate(tt.rows, tt.filter)
+
+			ids := []int{}
+			for _, s := range p.Snippets {
+				ids = append(ids, s.ID)
+			}
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Fatalf("want snippets %v; got %v", tt.wantIDs, ids)
+			}
+
+			checkCursor(t, "next", tt.wantNext, p.Next)
+			checkCursor(t, "prev", tt.wantPrev, p.Prev)
+		})
+	}
+}
+
+func checkCursor(t *testing.T, name string, want, got *Cursor) {
+	t.Helper()
+
+	switch {
+	case want == nil && got != nil:
+		t.Errorf("%s: want none; got %v", name, *got)
+	case want != nil && got == nil:
+		t.Errorf("%s: want %v; got none", name, *want)
+	case want != nil && (!want.Created.Equal(got.Created) || want.ID != got.ID):
+		t.Errorf("%s: want %v; got %v", name, *want, *got)
+	}
+}
@@ -0,0 +1,79 @@
+package mock
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+// PasswordResetModel keeps password reset tokens in memory, indexed by their
+// hash. Reset changes passwords in Users, which must be set.
+type PasswordResetModel struct {
+	Users *UserModel
+
+	mu     sync.Mutex
+	resets map[string]*reset
+}
+
+type reset struct {
+	userID  int
+	expires time.Time
+}
+
+func (m *PasswordResetModel) Insert(userID int) (string, error) {
+	token, hash, err := models.NewResetToken()
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.resets == nil {
+		m.resets = map[string]*reset{}
+	}
+	m.resets[hash] = &reset{userID: userID, expires: time.Now().Add(models.PasswordResetTTL)}
+
+	return token, nil
+}
+
+func (m *PasswordResetModel) UserID(token string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.resets[models.HashToken(token)]
+	if !ok || !time.Now().Before(r.expires) {
+		return 0, models.ErrNoRecord
+	}
+	return r.userID, nil
+}
+
+func (m *PasswordResetModel) Reset(token, password string) error {
+	// Use the minimum cost so tests which reset passwords stay fast.
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.resets[models.HashToken(token)]
+	if !ok || !time.Now().Before(r.expires) {
+		return models.ErrNoRecord
+	}
+
+	if err := m.Users.setPassword(r.userID, hashedPassword); err != nil {
+		return err
+	}
+
+	for hash, other := range m.resets {
+		if other.userID == r.userID {
+			delete(m.resets, hash)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,322 @@
+// Package mock provides in-memory implementations of the model stores. They
+// are meant for handler tests, where app.routes() can be exercised with
+// httptest without a running database.
+package mock
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+// SnippetModel keeps snippets in memory. The zero value is ready to use. If
+// Users is set it is used to fill in the Author of returned snippets.
+type SnippetModel struct {
+	Users *UserModel
+
+	mu             sync.Mutex
+	nextID         int
+	nextRevisionID int
+	snippets       []*models.Snippet
+	revisions      []*models.Revision
+	tags           map[int][]string
+}
+
+func (m *SnippetModel) Insert(userID int, title, content, language, expires string, tags []string) (int, error) {
+	days, err := strconv.Atoi(expires)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.nextID++
+	now := time.Now().UTC()
+	s := &models.Snippet{
+		ID:       m.nextID,
+		UserID:   userID,
+		Title:    title,
+		Content:  content,
+		Language: language,
+		Created:  now,
+		Expires:  now.AddDate(0, 0, days),
+	}
+	m.snippets = append(m.snippets, s)
+	m.addRevision(s.ID, userID, title, content)
+	m.setTags(s.ID, tags)
+
+	return s.ID, nil
+}
+
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if s := m.live(id); s != nil {
+		return m.copy(s), nil
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+// live returns the stored snippet with the given id unless it has expired.
+// The caller must hold m.mu.
+func (m *SnippetModel) live(id int) *models.Snippet {
+	for _, s := range m.snippets {
+		if s.ID == id && s.Expires.After(time.Now()) {
+			return s
+		}
+	}
+	return nil
+}
+
+func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snippets := []*models.Snippet{}
+	now := time.Now()
+	for _, s := range m.snippets {
+		if s.Expires.After(now) {
+			snippets = append(snippets, m.copy(s))
+		}
+	}
+
+	sort.SliceStable(snippets, func(i, j int) bool {
+		return snippets[i].Created.After(snippets[j].Created)
+	})
+	if len(snippets) > 10 {
+		snippets = snippets[:10]
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.snippets[:0]
+	n := 0
+	now := time.Now()
+	for _, s := range m.snippets {
+		if n < limit && !s.Expires.After(now) {
+			n++
+			continue
+		}
+		kept = append(kept, s)
+	}
+	m.snippets = kept
+
+	return n, nil
+}
+
+func (m *SnippetModel) ByUser(userID int) ([]*models.Snippet, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	snippets := []*models.Snippet{}
+	now := time.Now()
+	for i := len(m.snippets) - 1; i >= 0; i-- {
+		s := m.snippets[i]
+		if s.UserID == userID && s.Expires.After(now) {
+			snippets = append(snippets, m.copy(s))
+		}
+	}
+
+	return snippets, nil
+}
+
+func (m *SnippetModel) List(f models.SnippetFilter) (*models.SnippetPage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	desc := !f.Oldest
+	cursor := f.After
+	if f.Before != nil {
+		desc = !desc
+		cursor = f.Before
+	}
+
+	// before reports whether a sorts ahead of b in the order being read.
+	before := func(a, b *models.Snippet) bool {
+		if !a.Created.Equal(b.Created) {
+			return a.Created.Before(b.Created) != desc
+		}
+		return (a.ID < b.ID) != desc
+	}
+
+	now := time.Now()
+	rows := []*models.Snippet{}
+	for _, s := range m.snippets {
+		switch {
+		case !s.Expires.After(now):
+		case f.AuthorID != 0 && s.UserID != f.AuthorID:
+		case f.ExpiresWithin != 0 && s.Expires.After(now.AddDate(0, 0, f.ExpiresWithin)):
+		case cursor != nil && !before(&models.Snippet{Created: cursor.Created, ID: cursor.ID}, s):
+		default:
+			rows = append(rows, m.copy(s))
+		}
+	}
+
+	sort.Slice(rows, func(i, j int) bool { return before(rows[i], rows[j]) })
+	if len(rows) > f.Limit+1 {
+		rows = rows[:f.Limit+1]
+	}
+
+	return models.Paginate(rows, f), nil
+}
+
+// Search does a case-insensitive substring match of every query term against
+// titles and content, newest first.
+func (m *SnippetModel) Search(query string, page int) (*models.SearchResults, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	res := &models.SearchResults{Page: page}
+	terms := models.SearchTerms(strings.ToLower(query))
+	if len(terms) == 0 {
+		return res, nil
+	}
+
+	matches := []*models.Snippet{}
+	now := time.Now()
+	for i := len(m.snippets) - 1; i >= 0; i-- {
+		s := m.snippets[i]
+		text := strings.ToLower(s.Title + " " + s.Content)
+		found := s.Expires.After(now)
+		for _, t := range terms {
+			found = found && strings.Contains(text, t)
+		}
+		if found {
+			matches = append(matches, m.copy(s))
+		}
+	}
+
+	res.Total = len(matches)
+	start := min(models.Offset(page), len(matches))
+	end := min(start+models.SearchPageSize, len(matches))
+	res.Snippets = matches[start:end]
+
+	return res, nil
+}
+
+func (m *SnippetModel) Update(id, userID int, title, content, language, expires string, tags []string) error {
+	days := 0
+	if expires != "" {
+		var err error
+		days, err = strconv.Atoi(expires)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.snippets {
+		if s.ID == id && s.Expires.After(time.Now()) {
+			s.Title = title
+			s.Content = content
+			s.Language = language
+			if expires != "" {
+				s.Expires = time.Now().UTC().AddDate(0, 0, days)
+			}
+			m.addRevision(id, userID, title, content)
+			m.setTags(id, tags)
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) Delete(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, s := range m.snippets {
+		if s.ID == id {
+			m.snippets = append(m.snippets[:i], m.snippets[i+1:]...)
+
+			kept := m.revisions[:0]
+			for _, rev := range m.revisions {
+				if rev.SnippetID != id {
+					kept = append(kept, rev)
+				}
+			}
+			m.revisions = kept
+
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
+
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.Revision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	revisions := []*models.Revision{}
+	for i := len(m.revisions) - 1; i >= 0; i-- {
+		if m.revisions[i].SnippetID == snippetID {
+			revisions = append(revisions, m.copyRevision(m.revisions[i]))
+		}
+	}
+
+	return revisions, nil
+}
+
+func (m *SnippetModel) Revision(snippetID, id int) (*models.Revision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, rev := range m.revisions {
+		if rev.SnippetID == snippetID && rev.ID == id {
+			return m.copyRevision(rev), nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+// addRevision records a snippet's title and content. The caller must hold mu.
+func (m *SnippetModel) addRevision(snippetID, userID int, title, content string) {
+	m.nextRevisionID++
+	m.revisions = append(m.revisions, &models.Revision{
+		ID:        m.nextRevisionID,
+		SnippetID: snippetID,
+		UserID:    userID,
+		Title:     title,
+		Content:   content,
+		Created:   time.Now().UTC(),
+	})
+}
+
+func (m *SnippetModel) copyRevision(rev *models.Revision) *models.Revision {
+	c := *rev
+	if m.Users != nil && c.UserID != 0 {
+		if u, err := m.Users.Get(c.UserID); err == nil {
+			c.Author = u.Name
+		}
+	}
+	return &c
+}
+
+// copy hands out a copy of a stored snippet, so callers can't mutate it, with
+// the author's name filled in when Users is set.
+func (m *SnippetModel) copy(s *models.Snippet) *models.Snippet {
+	c := *s
+	if m.Users != nil && c.UserID != 0 {
+		if u, err := m.Users.Get(c.UserID); err == nil {
+			c.Author = u.Name
+		}
+	}
+	return &c
+}
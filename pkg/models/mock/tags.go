@@ -0,0 +1,94 @@
+package mock
+
+import (
+	"sort"
+
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+// TagModel reads the tags of the snippets in SnippetModel, which keeps them
+// so that they're written along with their snippet.
+type TagModel struct {
+	SnippetModel *SnippetModel
+}
+
+func (m *TagModel) ForSnippet(snippetID int) ([]string, error) {
+	sm := m.SnippetModel
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	return append([]string{}, sm.tags[snippetID]...), nil
+}
+
+func (m *TagModel) Snippets(name string) ([]*models.Snippet, error) {
+	sm := m.SnippetModel
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	snippets := []*models.Snippet{}
+	for id, names := range sm.tags {
+		if !contains(names, name) {
+			continue
+		}
+		if s := sm.live(id); s != nil {
+			snippets = append(snippets, sm.copy(s))
+		}
+	}
+
+	sort.Slice(snippets, func(i, j int) bool {
+		return snippets[i].ID > snippets[j].ID
+	})
+
+	return snippets, nil
+}
+
+func (m *TagModel) Cloud(limit int) ([]*models.Tag, error) {
+	sm := m.SnippetModel
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	counts := map[string]int{}
+	for id, names := range sm.tags {
+		if sm.live(id) == nil {
+			continue
+		}
+		for _, name := range names {
+			counts[name]++
+		}
+	}
+
+	tags := []*models.Tag{}
+	for name, n := range counts {
+		tags = append(tags, &models.Tag{Name: name, Count: n})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	if len(tags) > limit {
+		tags = tags[:limit]
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+
+	return tags, nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// setTags replaces the tags on a snippet. The caller must hold m.mu.
+func (m *SnippetModel) setTags(snippetID int, names []string) {
+	if m.tags == nil {
+		m.tags = map[int][]string{}
+	}
+	m.tags[snippetID] = append([]string(nil), names...)
+	sort.Strings(m.tags[snippetID])
+}
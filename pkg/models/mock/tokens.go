@@ -0,0 +1,88 @@
+package mock
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+// TokenModel keeps API tokens in memory, indexed by their hash. The zero
+// value is ready to use.
+type TokenModel struct {
+	mu     sync.Mutex
+	nextID int
+	tokens map[string]*models.Token
+}
+
+func (m *TokenModel) Insert(userID int, name, scope string) (string, error) {
+	token, hash, err := models.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.tokens == nil {
+		m.tokens = map[string]*models.Token{}
+	}
+	m.nextID++
+	m.tokens[hash] = &models.Token{
+		ID:      m.nextID,
+		UserID:  userID,
+		Name:    name,
+		Scope:   scope,
+		Created: time.Now().UTC(),
+	}
+
+	return token, nil
+}
+
+func (m *TokenModel) Authenticate(token string) (*models.Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tokens[models.HashToken(token)]
+	if !ok {
+		return nil, models.ErrInvalidCredentials
+	}
+
+	now := time.Now().UTC()
+	t.LastUsed = &now
+	c := *t
+	return &c, nil
+}
+
+func (m *TokenModel) ForUser(userID int) ([]*models.Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tokens := []*models.Token{}
+	for _, t := range m.tokens {
+		if t.UserID == userID {
+			c := *t
+			tokens = append(tokens, &c)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].ID > tokens[j].ID
+	})
+
+	return tokens, nil
+}
+
+func (m *TokenModel) Delete(id, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for hash, t := range m.tokens {
+		if t.ID == id && t.UserID == userID {
+			delete(m.tokens, hash)
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
@@ -0,0 +1,187 @@
+package mock
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+// UserModel keeps users in memory. The zero value is ready to use.
+type UserModel struct {
+	mu    sync.Mutex
+	users []*models.User
+	// secrets and recoveryCodes hold the TOTP secret and the hashes of the
+	// unused recovery codes of users with two-factor authentication on, and
+	// totpSteps the last TOTP time step each user entered a code for.
+	secrets       map[int]string
+	recoveryCodes map[int]map[string]bool
+	totpSteps     map[int]int64
+}
+
+func (m *UserModel) Insert(name, email, password string) error {
+	// Use the minimum cost so tests which sign users up stay fast.
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Email == email {
+			return models.ErrDuplicateEmail
+		}
+	}
+
+	m.users = append(m.users, &models.User{
+		ID:             len(m.users) + 1,
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        time.Now().UTC(),
+	})
+
+	return nil
+}
+
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Email != email {
+			continue
+		}
+		if bcrypt.CompareHashAndPassword(u.HashedPassword, []byte(password)) != nil {
+			return 0, models.ErrInvalidCredentials
+		}
+		return u.ID, nil
+	}
+
+	return 0, models.ErrInvalidCredentials
+}
+
+func (m *UserModel) Get(id int) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.ID == id {
+			c := *u
+			c.TOTPEnabled = m.secrets[id] != ""
+			return &c, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *UserModel) ByEmail(email string) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.Email == email {
+			c := *u
+			c.TOTPEnabled = m.secrets[u.ID] != ""
+			return &c, nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+func (m *UserModel) TOTPSecret(id int) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.exists(id) {
+		return "", models.ErrNoRecord
+	}
+	return m.secrets[id], nil
+}
+
+func (m *UserModel) EnableTOTP(id int, secret string, recoveryCodes []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.exists(id) {
+		return models.ErrNoRecord
+	}
+	if m.secrets == nil {
+		m.secrets = map[int]string{}
+		m.recoveryCodes = map[int]map[string]bool{}
+	}
+
+	m.secrets[id] = secret
+	m.recoveryCodes[id] = map[string]bool{}
+	for _, code := range recoveryCodes {
+		m.recoveryCodes[id][models.HashRecoveryCode(id, code)] = true
+	}
+
+	return nil
+}
+
+func (m *UserModel) DisableTOTP(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.secrets, id)
+	delete(m.recoveryCodes, id)
+	return nil
+}
+
+func (m *UserModel) UseRecoveryCode(id int, code string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	hash := models.HashRecoveryCode(id, code)
+	if !m.recoveryCodes[id][hash] {
+		return false, nil
+	}
+	delete(m.recoveryCodes[id], hash)
+	return true, nil
+}
+
+func (m *UserModel) UseTOTPStep(id int, step int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.exists(id) || step <= m.totpSteps[id] {
+		return false, nil
+	}
+	if m.totpSteps == nil {
+		m.totpSteps = map[int]int64{}
+	}
+	m.totpSteps[id] = step
+	return true, nil
+}
+
+// exists reports whether there's a user with the given ID. The caller must
+// hold m.mu.
+func (m *UserModel) exists(id int) bool {
+	for _, u := range m.users {
+		if u.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// setPassword replaces a user's password hash, for PasswordResetModel.
+func (m *UserModel) setPassword(id int, hashedPassword []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, u := range m.users {
+		if u.ID == id {
+			u.HashedPassword = hashedPassword
+			return nil
+		}
+	}
+
+	return models.ErrNoRecord
+}
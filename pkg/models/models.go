@@ -15,6 +15,18 @@ var (
 	ErrDuplicateEmail = errors.New("models: duplicate email")
 )
 
+// PasswordCost is the bcrypt cost passwords are hashed with.
+const PasswordCost = 12
+
+// MaxPasswordBytes is the longest password bcrypt can hash.
+const MaxPasswordBytes = 72
+
+// DummyPasswordHash is a bcrypt hash made with PasswordCost, like real
+// passwords. Authenticate compares against it when there's no user with the
+// given email, so a failed login takes as long whether or not the account
+// exists.
+var DummyPasswordHash = []byte("$2a$12$z5UjUY3cyvfmgJfROV55yuEpcs1StJLQ/jUlMOwHpph/bkWoTjACG")
+
 // Create database model
 type Snippet struct {
 	ID      int
@@ -22,6 +34,34 @@ type Snippet struct {
 	Content string
 	Created time.Time
 	Expires time.Time
+	// Language names the language of the content for syntax highlighting,
+	// or is empty for plain text.
+	Language string
+	// UserID and Author identify who wrote the snippet. They are zero for
+	// snippets created before authorship was recorded.
+	UserID int
+	Author string
+	// Tags is only filled in where a page displays the snippet's tags.
+	Tags []string
+}
+
+// Tag is a label attached to snippets, with the number of unexpired
+// snippets carrying it.
+type Tag struct {
+	Name  string
+	Count int
+}
+
+// Revision is a snapshot of a snippet's title and content, recorded every
+// time the snippet is created or edited.
+type Revision struct {
+	ID        int
+	SnippetID int
+	UserID    int
+	Author    string
+	Title     string
+	Content   string
+	Created   time.Time
 }
 
 type User struct {
@@ -30,4 +70,91 @@ type User struct {
 	Email          string
 	HashedPassword []byte
 	Created        time.Time
+	// TOTPEnabled is set when the user has turned on two-factor
+	// authentication.
+	TOTPEnabled bool
+}
+
+// SnippetStore describes the operations the web application needs on
+// snippets. Handlers depend on this interface instead of a concrete database
+// model, so any backend (or the in-memory mock) can be plugged in.
+type SnippetStore interface {
+	// Insert and Update write a snippet together with its tags.
+	Insert(userID int, title, content, language, expires string, tags []string) (int, error)
+	Get(id int) (*Snippet, error)
+	Latest() ([]*Snippet, error)
+	ByUser(userID int) ([]*Snippet, error)
+	List(f SnippetFilter) (*SnippetPage, error)
+	Search(query string, page int) (*SearchResults, error)
+	// Update changes a snippet. An empty expires keeps its expiry date. It
+	// returns ErrNoRecord if the snippet doesn't exist or has expired.
+	Update(id, userID int, title, content, language, expires string, tags []string) error
+	Delete(id int) error
+	Revisions(snippetID int) ([]*Revision, error)
+	Revision(snippetID, id int) (*Revision, error)
+	DeleteExpired(limit int) (int, error)
+}
+
+// UserStore describes the operations the web application needs on users.
+type UserStore interface {
+	Insert(name, email, password string) error
+	Authenticate(email, password string) (int, error)
+	Get(id int) (*User, error)
+	// ByEmail looks a user up by their email address. It returns
+	// ErrNoRecord if there's no such user.
+	ByEmail(email string) (*User, error)
+	// TOTPSecret returns the user's TOTP secret, or "" if two-factor
+	// authentication is off.
+	TOTPSecret(id int) (string, error)
+	// EnableTOTP turns on two-factor authentication with the given secret,
+	// replacing any recovery codes with recoveryCodes.
+	EnableTOTP(id int, secret string, recoveryCodes []string) error
+	// DisableTOTP turns off two-factor authentication and deletes the
+	// user's recovery codes.
+	DisableTOTP(id int) error
+	// UseRecoveryCode reports whether code is one of the user's unused
+	// recovery codes, and if so uses it up.
+	UseRecoveryCode(id int, code string) (bool, error)
+	// UseTOTPStep records that the user entered the TOTP code for the given
+	// time step. It reports false if that step or a later one was used
+	// already, so each code works only once.
+	UseTOTPStep(id int, step int64) (bool, error)
+}
+
+// TagStore describes the operations the web application needs on tags. Tags
+// are written along with their snippet by SnippetStore.
+type TagStore interface {
+	ForSnippet(snippetID int) ([]string, error)
+	Snippets(name string) ([]*Snippet, error)
+	Cloud(limit int) ([]*Tag, error)
+}
+
+// TokenStore describes the operations the web application needs on API
+// tokens.
+type TokenStore interface {
+	// Insert creates a token for a user and returns the plain token, which
+	// can't be recovered later.
+	Insert(userID int, name, scope string) (string, error)
+	// Authenticate looks up a token and records that it was used. It returns
+	// ErrInvalidCredentials if there is no such token.
+	Authenticate(token string) (*Token, error)
+	ForUser(userID int) ([]*Token, error)
+	// Delete revokes one of a user's tokens. It returns ErrNoRecord if the
+	// user has no token with that ID.
+	Delete(id, userID int) error
+}
+
+// PasswordResetStore describes the operations the web application needs on
+// password reset tokens.
+type PasswordResetStore interface {
+	// Insert creates a reset token for a user, valid for PasswordResetTTL,
+	// and returns the plain token. Only its hash is stored.
+	Insert(userID int) (string, error)
+	// UserID returns the ID of the user an unexpired token belongs to. It
+	// returns ErrNoRecord for unknown, used or expired tokens.
+	UserID(token string) (int, error)
+	// Reset sets the password of the user a valid token belongs to, and
+	// uses up all of that user's tokens. It returns ErrNoRecord for
+	// unknown, used or expired tokens.
+	Reset(token, password string) error
 }
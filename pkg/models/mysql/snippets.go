@@ -2,6 +2,8 @@ package mysql
 
 import (
 	"database/sql"
+	"fmt"
+	"strings"
 
 	"vincellauderes.net/snippetbox/pkg/models"
 )
@@ -10,40 +12,70 @@ type SnippetModel struct {
 	DB *sql.DB
 }
 
+// snippetColumns selects a snippet along with its author's name. The LEFT
+// JOIN keeps snippets which were created before authorship was recorded.
+const snippetColumns = `s.id, s.title, s.content, s.created, s.expires, s.language,
+	COALESCE(s.user_id, 0), COALESCE(u.name, '')
+	FROM snippets s LEFT JOIN users u ON u.id = s.user_id`
+
+// revisionColumns selects a revision along with the name of its author.
+const revisionColumns = `r.id, r.snippet_id, COALESCE(r.user_id, 0), COALESCE(u.name, ''),
+	r.title, r.content, r.created
+	FROM snippet_revisions r LEFT JOIN users u ON u.id = r.user_id`
+
 // This will insert a new snippet into the database.
-func (m *SnippetModel) Insert(title, content, expires string) (int, error) {
+func (m *SnippetModel) Insert(userID int, title, content, language, expires string, tags []string) (int, error) {
 
 	// Write the SQL statement we want to execute. I've split its over to two lines
 	// for readability (which is why it's surrounded with back quotes instead of normal double quotes).
-	stmt := `INSERT INTO snippets (title, content, created, expires)
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	stmt := `INSERT INTO snippets (user_id, title, content, language, created, expires)
+	VALUES(?, ?, ?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
 
-	// DB Exec is way to execute queries to the database
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	// Write the snippet with its tags and first revision together, so a
+	// snippet never exists without any history.
+	tx, err := m.DB.Begin()
 	if err != nil {
-		return 0, nil
+		return 0, err
+	}
+
+	// Exec is way to execute queries to the database
+	result, err := tx.Exec(stmt, userID, title, content, language, expires)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
 	}
 
 	id, err := result.LastInsertId()
 	if err != nil {
-		return 0, nil
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err = insertRevision(tx, int(id), userID, title, content); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err = setTags(tx, int(id), tags); err != nil {
+		tx.Rollback()
+		return 0, err
 	}
 
-	return int(id), nil
+	return int(id), tx.Commit()
 
 }
 
 // This will return a specific snippet based on its id.
 func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	stmt := `SELECT ` + snippetColumns + `
+	WHERE s.expires > UTC_TIMESTAMP() AND s.id = ?`
 
 	row := m.DB.QueryRow(stmt, id)
 
 	// Initialize a pointer to a new zeroed Snippet struct.
 	s := &models.Snippet{}
 
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.Language, &s.UserID, &s.Author)
 	if err == sql.ErrNoRows {
 		// Use the defined error in models so prevent being dependent to database error.
 		return nil, models.ErrNoRecord
@@ -57,8 +89,8 @@ func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
 
 // This will return the 10 most recently created snippets
 func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets
-	WHERE expires > UTC_TIMESTAMP() ORDER BY created DESC LIMIT 10`
+	stmt := `SELECT ` + snippetColumns + `
+	WHERE s.expires > UTC_TIMESTAMP() ORDER BY s.created DESC LIMIT 10`
 
 	// Use the Query() method on the connection pool to execute our
 	// SQL statement. This returns a sql.Rows resultset containing the result on
@@ -68,11 +100,246 @@ func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 		return nil, err
 	}
 
+	return scanSnippets(rows)
+}
+
+// ByUser returns the unexpired snippets written by a user, newest first.
+func (m *SnippetModel) ByUser(userID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `
+	WHERE s.expires > UTC_TIMESTAMP() AND s.user_id = ? ORDER BY s.created DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSnippets(rows)
+}
+
+// List returns a page of unexpired snippets matching the filter. It uses
+// keyset pagination on (created, id), so pages stay stable and cheap to fetch
+// however deep into the listing they are.
+func (m *SnippetModel) List(f models.SnippetFilter) (*models.SnippetPage, error) {
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "?"
+	}
+
+	where := []string{"s.expires > UTC_TIMESTAMP()"}
+	if f.AuthorID != 0 {
+		where = append(where, "s.user_id = "+arg(f.AuthorID))
+	}
+	if f.ExpiresWithin != 0 {
+		where = append(where, "s.expires <= DATE_ADD(UTC_TIMESTAMP(), INTERVAL "+arg(f.ExpiresWithin)+" DAY)")
+	}
+
+	// Newest first unless asked otherwise. Paging backwards reads the rows
+	// in the opposite order, and models.Paginate flips them back.
+	desc := !f.Oldest
+	cursor := f.After
+	if f.Before != nil {
+		desc = !desc
+		cursor = f.Before
+	}
+	cmp, order := ">", "ASC"
+	if desc {
+		cmp, order = "<", "DESC"
+	}
+	if cursor != nil {
+		created := cursor.Created
+		where = append(where, fmt.Sprintf("(s.created %s %s OR (s.created = %s AND s.id %s %s))",
+			cmp, arg(created), arg(created), cmp, arg(cursor.ID)))
+	}
+
+	stmt := fmt.Sprintf("SELECT %s WHERE %s ORDER BY s.created %s, s.id %s LIMIT %s",
+		snippetColumns, strings.Join(where, " AND "), order, order, arg(f.Limit+1))
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.Paginate(snippets, f), nil
+}
+
+// Search finds unexpired snippets matching a query using the FULLTEXT index
+// on title and content, most relevant first.
+func (m *SnippetModel) Search(query string, page int) (*models.SearchResults, error) {
+	res := &models.SearchResults{Page: page}
+
+	match := `MATCH(s.title, s.content) AGAINST(? IN NATURAL LANGUAGE MODE)`
+
+	stmt := `SELECT COUNT(*) FROM snippets s WHERE s.expires > UTC_TIMESTAMP() AND ` + match
+	if err := m.DB.QueryRow(stmt, query).Scan(&res.Total); err != nil {
+		return nil, err
+	}
+
+	stmt = `SELECT ` + snippetColumns + `
+	WHERE s.expires > UTC_TIMESTAMP() AND ` + match + `
+	ORDER BY ` + match + ` DESC, s.id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, query, query, models.SearchPageSize, models.Offset(page))
+	if err != nil {
+		return nil, err
+	}
+
+	res.Snippets, err = scanSnippets(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Update replaces the title, content, language and tags of a snippet and
+// records the change as a new revision by userID. Unless expires is empty, it
+// also restarts the snippet's expiry countdown from now. It returns
+// models.ErrNoRecord if there is no such snippet or it has expired.
+func (m *SnippetModel) Update(id, userID int, title, content, language, expires string, tags []string) error {
+	stmt := `UPDATE snippets SET title = ?, content = ?, language = ?,
+	expires = DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY)
+	WHERE id = ? AND expires > UTC_TIMESTAMP()`
+	args := []any{title, content, language, expires, id}
+	if expires == "" {
+		stmt = `UPDATE snippets SET title = ?, content = ?, language = ?
+		WHERE id = ? AND expires > UTC_TIMESTAMP()`
+		args = []any{title, content, language, id}
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(stmt, args...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if n == 0 {
+		// MySQL only counts rows which actually changed, so an edit which
+		// keeps everything as it was affects none. Look for the snippet.
+		var ok bool
+		err = tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM snippets
+		WHERE id = ? AND expires > UTC_TIMESTAMP())`, id).Scan(&ok)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if !ok {
+			tx.Rollback()
+			return models.ErrNoRecord
+		}
+	}
+
+	if err = insertRevision(tx, id, userID, title, content); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = setTags(tx, id, tags); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Revisions returns the history of a snippet, newest first.
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.Revision, error) {
+	stmt := `SELECT ` + revisionColumns + `
+	WHERE r.snippet_id = ? ORDER BY r.id DESC`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+
+	for rows.Next() {
+		rev := &models.Revision{}
+
+		err := rows.Scan(&rev.ID, &rev.SnippetID, &rev.UserID, &rev.Author, &rev.Title, &rev.Content, &rev.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// Revision returns a single revision of a snippet.
+func (m *SnippetModel) Revision(snippetID, id int) (*models.Revision, error) {
+	stmt := `SELECT ` + revisionColumns + `
+	WHERE r.snippet_id = ? AND r.id = ?`
+
+	rev := &models.Revision{}
+	err := m.DB.QueryRow(stmt, snippetID, id).Scan(&rev.ID, &rev.SnippetID, &rev.UserID, &rev.Author, &rev.Title, &rev.Content, &rev.Created)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	return rev, nil
+}
+
+// insertRevision records the current title and content of a snippet as part
+// of the transaction which changed them.
+func insertRevision(tx *sql.Tx, snippetID, userID int, title, content string) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, user_id, title, content, created)
+	VALUES(?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err := tx.Exec(stmt, snippetID, userID, title, content)
+	return err
+}
+
+// Delete removes a snippet. It returns models.ErrNoRecord if there was no
+// snippet with the given id.
+func (m *SnippetModel) Delete(id int) error {
+	result, err := m.DB.Exec(`DELETE FROM snippets WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// scanSnippets reads every row selected with snippetColumns and closes rows.
+func scanSnippets(rows *sql.Rows) ([]*models.Snippet, error) {
 	// We defer rows.Close() to ensure the sql.Rows resultset is
-	// always properly closed before the Latest() method returns. This defer
-	// statement should come *after* you check for an error from the Query()
-	// method. Otherwise, if Query() returns an error, you'll get a panic
-	// trying to close a nil resultset.
+	// always properly closed before scanSnippets returns. Callers should only
+	// pass rows in *after* checking the error from Query(). Otherwise, if
+	// Query() returns an error, you'll get a panic trying to close a nil
+	// resultset.
 	defer rows.Close()
 
 	snippets := []*models.Snippet{}
@@ -80,7 +347,7 @@ func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 	for rows.Next() {
 		s := &models.Snippet{}
 
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err := rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.Language, &s.UserID, &s.Author)
 		if err != nil {
 			return nil, err
 		}
@@ -93,13 +360,31 @@ func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
 	// error that was encountered during the iteration. It's important to
 	// call this - don't assume that a successful iteration was completed
 	// over the whole resultset.
-	if err = rows.Err(); err != nil {
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
 	return snippets, nil
 }
 
+// DeleteExpired removes up to limit snippets whose expiry time has passed and
+// returns how many rows were deleted.
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	stmt := `DELETE FROM snippets WHERE expires <= UTC_TIMESTAMP() ORDER BY expires LIMIT ?`
+
+	result, err := m.DB.Exec(stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
+
 type ExampleModel struct {
 	DB *sql.DB
 }
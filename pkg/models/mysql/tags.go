@@ -0,0 +1,113 @@
+package mysql
+
+import (
+	"database/sql"
+
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+type TagModel struct {
+	DB *sql.DB
+}
+
+// ForSnippet returns the names of a snippet's tags in alphabetical order.
+func (m *TagModel) ForSnippet(snippetID int) ([]string, error) {
+	stmt := `SELECT t.name FROM tags t
+	JOIN snippet_tags st ON st.tag_id = t.id
+	WHERE st.snippet_id = ? ORDER BY t.name`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// Snippets returns the unexpired snippets carrying a tag, newest first.
+func (m *TagModel) Snippets(name string) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `
+	JOIN snippet_tags st ON st.snippet_id = s.id
+	JOIN tags t ON t.id = st.tag_id
+	WHERE s.expires > UTC_TIMESTAMP() AND t.name = ?
+	ORDER BY s.created DESC, s.id DESC`
+
+	rows, err := m.DB.Query(stmt, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSnippets(rows)
+}
+
+// Cloud returns up to limit of the most used tags among unexpired snippets,
+// in alphabetical order.
+func (m *TagModel) Cloud(limit int) ([]*models.Tag, error) {
+	stmt := `SELECT name, n FROM (
+		SELECT t.name, COUNT(*) AS n FROM tags t
+		JOIN snippet_tags st ON st.tag_id = t.id
+		JOIN snippets s ON s.id = st.snippet_id
+		WHERE s.expires > UTC_TIMESTAMP()
+		GROUP BY t.name ORDER BY n DESC, t.name LIMIT ?
+	) AS top ORDER BY name`
+
+	rows, err := m.DB.Query(stmt, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []*models.Tag{}
+	for rows.Next() {
+		t := &models.Tag{}
+		if err := rows.Scan(&t.Name, &t.Count); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// setTags replaces the tags on a snippet, creating any tags which don't exist
+// yet, as part of the transaction which writes the snippet.
+func setTags(tx *sql.Tx, snippetID int, names []string) error {
+	_, err := tx.Exec("DELETE FROM snippet_tags WHERE snippet_id = ?", snippetID)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		_, err = tx.Exec("INSERT IGNORE INTO tags (name) VALUES (?)", name)
+		if err != nil {
+			return err
+		}
+
+		stmt := `INSERT IGNORE INTO snippet_tags (snippet_id, tag_id)
+		SELECT ?, id FROM tags WHERE name = ?`
+		_, err = tx.Exec(stmt, snippetID, name)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,7 +2,6 @@ package mysql
 
 import (
 	"database/sql"
-	"fmt"
 	"strings"
 
 	"github.com/go-sql-driver/mysql"
@@ -16,7 +15,7 @@ type UserModel struct {
 
 func (m *UserModel) Insert(name, email, password string) error {
 	// Create a bcrypt hash of the plain-text password.
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), models.PasswordCost)
 	if err != nil {
 		return err
 	}
@@ -27,7 +26,6 @@ func (m *UserModel) Insert(name, email, password string) error {
 	_, err = m.DB.Exec(stmt, name, email, string(hashedPassword))
 	if err != nil {
 		if mysqlErr, ok := err.(*mysql.MySQLError); ok {
-			fmt.Println(mysqlErr.Message)
 			if mysqlErr.Number == 1062 && strings.Contains(mysqlErr.Message, "users.users_uc_email") {
 				return models.ErrDuplicateEmail
 			}
@@ -46,6 +44,7 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 	err := row.Scan(&id, &hashedPassword)
 
 	if err == sql.ErrNoRows {
+		bcrypt.CompareHashAndPassword(models.DummyPasswordHash, []byte(password))
 		return 0, models.ErrInvalidCredentials
 	} else if err != nil {
 		return 0, err
@@ -62,5 +61,141 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 }
 
 func (m *UserModel) Get(id int) (*models.User, error) {
-	return nil, nil
+	u := &models.User{}
+
+	stmt := `SELECT id, name, email, created, totp_secret IS NOT NULL FROM users WHERE id = ?`
+	err := m.DB.QueryRow(stmt, id).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.TOTPEnabled)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// ByEmail looks a user up by their email address.
+func (m *UserModel) ByEmail(email string) (*models.User, error) {
+	u := &models.User{}
+
+	stmt := `SELECT id, name, email, created, totp_secret IS NOT NULL FROM users WHERE email = ?`
+	err := m.DB.QueryRow(stmt, email).Scan(&u.ID, &u.Name, &u.Email, &u.Created, &u.TOTPEnabled)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// TOTPSecret returns the user's TOTP secret, or "" if two-factor
+// authentication is off.
+func (m *UserModel) TOTPSecret(id int) (string, error) {
+	var secret sql.NullString
+	err := m.DB.QueryRow("SELECT totp_secret FROM users WHERE id = ?", id).Scan(&secret)
+	if err == sql.ErrNoRows {
+		return "", models.ErrNoRecord
+	} else if err != nil {
+		return "", err
+	}
+
+	return secret.String, nil
+}
+
+// EnableTOTP stores the user's TOTP secret and replaces their recovery codes
+// with the hashes of recoveryCodes.
+func (m *UserModel) EnableTOTP(id int, secret string, recoveryCodes []string) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec("UPDATE users SET totp_secret = ? WHERE id = ?", secret, id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if rows == 0 {
+		tx.Rollback()
+		return models.ErrNoRecord
+	}
+
+	_, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, code := range recoveryCodes {
+		_, err = tx.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)", id, models.HashRecoveryCode(id, code))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DisableTOTP clears the user's TOTP secret and deletes their recovery codes.
+func (m *UserModel) DisableTOTP(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE users SET totp_secret = NULL WHERE id = ?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", id)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode deletes the matching recovery code, so each one works only
+// once, and reports whether there was one.
+func (m *UserModel) UseRecoveryCode(id int, code string) (bool, error) {
+	stmt := "DELETE FROM recovery_codes WHERE user_id = ? AND code_hash = ?"
+	result, err := m.DB.Exec(stmt, id, models.HashRecoveryCode(id, code))
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+// UseTOTPStep moves the user's last used TOTP time step forward to step. The
+// condition makes the check and the update one atomic statement, so the same
+// code sent twice at once is only accepted once.
+func (m *UserModel) UseTOTPStep(id int, step int64) (bool, error) {
+	stmt := "UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?"
+	result, err := m.DB.Exec(stmt, step, id, step)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
 }
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SearchPageSize is the number of results on each page of a search.
+const SearchPageSize = 20
+
+// SearchResults is one page of the unexpired snippets matching a query, best
+// matches first.
+type SearchResults struct {
+	Snippets []*Snippet
+	// Total counts the matches across all pages.
+	Total int
+	// Page is the 1-based number of this page.
+	Page int
+}
+
+// HasPrev reports whether there's a page before this one.
+func (s *SearchResults) HasPrev() bool {
+	return s.Page > 1
+}
+
+// HasNext reports whether there's a page after this one.
+func (s *SearchResults) HasNext() bool {
+	return s.Page*SearchPageSize < s.Total
+}
+
+// Offset returns how many results come before the given page.
+func Offset(page int) int {
+	if page < 1 {
+		return 0
+	}
+	return (page - 1) * SearchPageSize
+}
+
+// SearchTerms splits a query into the words to look for, dropping the
+// punctuation which search engines treat as operators.
+func SearchTerms(query string) []string {
+	return strings.FieldsFunc(query, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '_'
+	})
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"Empty", "", nil},
+		{"Blank", "  \t\n", nil},
+		{"Only punctuation", `"*" -- ()`, nil},
+		{"Words", "silent pond", []string{"silent", "pond"}},
+		{"Quotes", `"silent pond" 'frog'`, []string{"silent", "pond", "frog"}},
+		{"Unbalanced quote", `"silent pond`, []string{"silent", "pond"}},
+		{"Prefix and exclusion", "pond* -frog +jump ^old", []string{"pond", "frog", "jump", "old"}},
+		{"Column filter", "title:haiku content:pond", []string{"title", "haiku", "content", "pond"}},
+		{"Grouping", "NEAR(silent pond, 5)", []string{"NEAR", "silent", "pond", "5"}},
+		{"Boolean operators", "pond AND frog OR NOT jump", []string{"pond", "AND", "frog", "OR", "NOT", "jump"}},
+		{"Symbols", "a+b=c; x|y & {z}", []string{"a", "b", "c", "x", "y", "z"}},
+		{"Identifiers", "snake_case and x86", []string{"snake_case", "and", "x86"}},
+		{"Unicode", "café naïve 東京", []string{"café", "naïve", "東京"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SearchTerms(tt.query)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("want %q; got %q", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"golang.org/x/crypto/bcrypt"
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+type PasswordResetModel struct {
+	DB *sql.DB
+}
+
+// Insert creates a password reset token for a user and returns the plain
+// token. Only its hash is stored.
+func (m *PasswordResetModel) Insert(userID int) (string, error) {
+	token, hash, err := models.NewResetToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO password_resets (user_id, token_hash, created, expires)
+	VALUES (?, ?, datetime('now'), datetime('now', '+' || ? || ' minutes'))`
+
+	_, err = m.DB.Exec(stmt, userID, hash, int(models.PasswordResetTTL.Minutes()))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// UserID returns the ID of the user an unexpired token belongs to.
+func (m *PasswordResetModel) UserID(token string) (int, error) {
+	var userID int
+	stmt := `SELECT user_id FROM password_resets WHERE token_hash = ? AND expires > datetime('now')`
+	err := m.DB.QueryRow(stmt, models.HashToken(token)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, models.ErrNoRecord
+	} else if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// Reset sets a new password for the user the token belongs to. All of the
+// user's reset tokens are deleted along with it, so a link works only once
+// and older links stop working too.
+func (m *PasswordResetModel) Reset(token, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), models.PasswordCost)
+	if err != nil {
+		return err
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	var userID int
+	stmt := `SELECT user_id FROM password_resets WHERE token_hash = ? AND expires > datetime('now')`
+	err = tx.QueryRow(stmt, models.HashToken(token)).Scan(&userID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return models.ErrNoRecord
+	} else if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE users SET hashed_password = ? WHERE id = ?", string(hashedPassword), userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM password_resets WHERE user_id = ?", userID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
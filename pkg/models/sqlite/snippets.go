@@ -0,0 +1,372 @@
+// Package sqlite implements the model stores on top of SQLite, so the whole
+// application can run from a single local database file.
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+// timeFormat is the layout of the DATETIME text SQLite's datetime() function
+// produces. Times bound as query parameters must use it to compare correctly.
+const timeFormat = "2006-01-02 15:04:05"
+
+type SnippetModel struct {
+	DB *sql.DB
+}
+
+// snippetColumns selects a snippet along with its author's name. The LEFT
+// JOIN keeps snippets which were created before authorship was recorded.
+const snippetColumns = `s.id, s.title, s.content, s.created, s.expires, s.language,
+	COALESCE(s.user_id, 0), COALESCE(u.name, '')
+	FROM snippets s LEFT JOIN users u ON u.id = s.user_id`
+
+// revisionColumns selects a revision along with the name of its author.
+const revisionColumns = `r.id, r.snippet_id, COALESCE(r.user_id, 0), COALESCE(u.name, ''),
+	r.title, r.content, r.created
+	FROM snippet_revisions r LEFT JOIN users u ON u.id = r.user_id`
+
+// This will insert a new snippet into the database.
+func (m *SnippetModel) Insert(userID int, title, content, language, expires string, tags []string) (int, error) {
+	// SQLite has no DATETIME type of its own, so timestamps are stored as
+	// 'YYYY-MM-DD HH:MM:SS' UTC text, which sorts and compares correctly.
+	stmt := `INSERT INTO snippets (user_id, title, content, language, created, expires)
+	VALUES(?, ?, ?, ?, datetime('now'), datetime('now', '+' || ? || ' days'))`
+
+	// Write the snippet with its tags and first revision together, so a
+	// snippet never exists without any history.
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec(stmt, userID, title, content, language, expires)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err = insertRevision(tx, int(id), userID, title, content); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	if err = setTags(tx, int(id), tags); err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
+}
+
+// This will return a specific snippet based on its id.
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `
+	WHERE s.expires > datetime('now') AND s.id = ?`
+
+	s := &models.Snippet{}
+	err := m.DB.QueryRow(stmt, id).Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.Language, &s.UserID, &s.Author)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// This will return the 10 most recently created snippets
+func (m *SnippetModel) Latest() ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `
+	WHERE s.expires > datetime('now') ORDER BY s.created DESC LIMIT 10`
+
+	rows, err := m.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSnippets(rows)
+}
+
+// ByUser returns the unexpired snippets written by a user, newest first.
+func (m *SnippetModel) ByUser(userID int) ([]*models.Snippet, error) {
+	stmt := `SELECT ` + snippetColumns + `
+	WHERE s.expires > datetime('now') AND s.user_id = ? ORDER BY s.created DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSnippets(rows)
+}
+
+// List returns a page of unexpired snippets matching the filter. It uses
+// keyset pagination on (created, id), so pages stay stable and cheap to fetch
+// however deep into the listing they are.
+func (m *SnippetModel) List(f models.SnippetFilter) (*models.SnippetPage, error) {
+	args := []interface{}{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "?"
+	}
+
+	where := []string{"s.expires > datetime('now')"}
+	if f.AuthorID != 0 {
+		where = append(where, "s.user_id = "+arg(f.AuthorID))
+	}
+	if f.ExpiresWithin != 0 {
+		where = append(where, "s.expires <= datetime('now', '+' || "+arg(f.ExpiresWithin)+" || ' days')")
+	}
+
+	// Newest first unless asked otherwise. Paging backwards reads the rows
+	// in the opposite order, and models.Paginate flips them back.
+	desc := !f.Oldest
+	cursor := f.After
+	if f.Before != nil {
+		desc = !desc
+		cursor = f.Before
+	}
+	cmp, order := ">", "ASC"
+	if desc {
+		cmp, order = "<", "DESC"
+	}
+	if cursor != nil {
+		created := cursor.Created.UTC().Format(timeFormat)
+		where = append(where, fmt.Sprintf("(s.created %s %s OR (s.created = %s AND s.id %s %s))",
+			cmp, arg(created), arg(created), cmp, arg(cursor.ID)))
+	}
+
+	stmt := fmt.Sprintf("SELECT %s WHERE %s ORDER BY s.created %s, s.id %s LIMIT %s",
+		snippetColumns, strings.Join(where, " AND "), order, order, arg(f.Limit+1))
+
+	rows, err := m.DB.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	snippets, err := scanSnippets(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return models.Paginate(snippets, f), nil
+}
+
+// Search finds unexpired snippets matching a query using the snippets_fts
+// index, best ranked first.
+func (m *SnippetModel) Search(query string, page int) (*models.SearchResults, error) {
+	res := &models.SearchResults{Page: page}
+
+	// Quote every term so characters which mean something in the FTS5 query
+	// syntax are searched for literally. Separate terms must all match.
+	terms := models.SearchTerms(query)
+	if len(terms) == 0 {
+		return res, nil
+	}
+	for i, t := range terms {
+		terms[i] = `"` + t + `"`
+	}
+	match := strings.Join(terms, " ")
+
+	stmt := `SELECT COUNT(*) FROM snippets s JOIN snippets_fts f ON f.rowid = s.id
+	WHERE s.expires > datetime('now') AND snippets_fts MATCH ?`
+	if err := m.DB.QueryRow(stmt, match).Scan(&res.Total); err != nil {
+		return nil, err
+	}
+
+	stmt = `SELECT ` + snippetColumns + `
+	JOIN snippets_fts f ON f.rowid = s.id
+	WHERE s.expires > datetime('now') AND snippets_fts MATCH ?
+	ORDER BY f.rank, s.id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, match, models.SearchPageSize, models.Offset(page))
+	if err != nil {
+		return nil, err
+	}
+
+	res.Snippets, err = scanSnippets(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// Update replaces the title, content, language and tags of a snippet and
+// records the change as a new revision by userID. Unless expires is empty, it
+// also restarts the snippet's expiry countdown from now. It returns
+// models.ErrNoRecord if there is no such snippet or it has expired.
+func (m *SnippetModel) Update(id, userID int, title, content, language, expires string, tags []string) error {
+	stmt := `UPDATE snippets SET title = ?, content = ?, language = ?,
+	expires = datetime('now', '+' || ? || ' days')
+	WHERE id = ? AND expires > datetime('now')`
+	args := []any{title, content, language, expires, id}
+	if expires == "" {
+		stmt = `UPDATE snippets SET title = ?, content = ?, language = ?
+		WHERE id = ? AND expires > datetime('now')`
+		args = []any{title, content, language, id}
+	}
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.Exec(stmt, args...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if n == 0 {
+		tx.Rollback()
+		return models.ErrNoRecord
+	}
+
+	if err = insertRevision(tx, id, userID, title, content); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err = setTags(tx, id, tags); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Revisions returns the history of a snippet, newest first.
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.Revision, error) {
+	stmt := `SELECT ` + revisionColumns + `
+	WHERE r.snippet_id = ? ORDER BY r.id DESC`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+
+	for rows.Next() {
+		rev := &models.Revision{}
+
+		err := rows.Scan(&rev.ID, &rev.SnippetID, &rev.UserID, &rev.Author, &rev.Title, &rev.Content, &rev.Created)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, rev)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
+
+// Revision returns a single revision of a snippet.
+func (m *SnippetModel) Revision(snippetID, id int) (*models.Revision, error) {
+	stmt := `SELECT ` + revisionColumns + `
+	WHERE r.snippet_id = ? AND r.id = ?`
+
+	rev := &models.Revision{}
+	err := m.DB.QueryRow(stmt, snippetID, id).Scan(&rev.ID, &rev.SnippetID, &rev.UserID, &rev.Author, &rev.Title, &rev.Content, &rev.Created)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrNoRecord
+	} else if err != nil {
+		return nil, err
+	}
+
+	return rev, nil
+}
+
+// insertRevision records the current title and content of a snippet as part
+// of the transaction which changed them.
+func insertRevision(tx *sql.Tx, snippetID, userID int, title, content string) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, user_id, title, content, created)
+	VALUES(?, ?, ?, ?, datetime('now'))`
+
+	_, err := tx.Exec(stmt, snippetID, userID, title, content)
+	return err
+}
+
+// Delete removes a snippet. It returns models.ErrNoRecord if there was no
+// snippet with the given id.
+func (m *SnippetModel) Delete(id int) error {
+	result, err := m.DB.Exec(`DELETE FROM snippets WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// scanSnippets reads every row selected with snippetColumns and closes rows.
+func scanSnippets(rows *sql.Rows) ([]*models.Snippet, error) {
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		s := &models.Snippet{}
+
+		err := rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires, &s.Language, &s.UserID, &s.Author)
+		if err != nil {
+			return nil, err
+		}
+
+		snippets = append(snippets, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return snippets, nil
+}
+
+// DeleteExpired removes up to limit snippets whose expiry time has passed and
+// returns how many rows were deleted.
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	// DELETE ... LIMIT isn't available here, so pick the batch in a subquery.
+	stmt := `DELETE FROM snippets WHERE id IN (
+		SELECT id FROM snippets WHERE expires <= datetime('now') ORDER BY expires LIMIT ?)`
+
+	result, err := m.DB.Exec(stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(n), nil
+}
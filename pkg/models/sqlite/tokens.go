@@ -0,0 +1,95 @@
+package sqlite
+
+import (
+	"database/sql"
+
+	"vincellauderes.net/snippetbox/pkg/models"
+)
+
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// Insert creates an API token for a user and returns the plain token. Only
+// its hash is stored.
+func (m *TokenModel) Insert(userID int, name, scope string) (string, error) {
+	token, hash, err := models.NewToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO api_tokens (user_id, name, scope, token_hash, created)
+	VALUES (?, ?, ?, ?, datetime('now'))`
+
+	_, err = m.DB.Exec(stmt, userID, name, scope, hash)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Authenticate looks up a token by its hash and records that it was used.
+func (m *TokenModel) Authenticate(token string) (*models.Token, error) {
+	t := &models.Token{}
+	stmt := `SELECT id, user_id, name, scope, created, last_used FROM api_tokens
+	WHERE token_hash = ?`
+	err := m.DB.QueryRow(stmt, models.HashToken(token)).Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &t.LastUsed)
+	if err == sql.ErrNoRows {
+		return nil, models.ErrInvalidCredentials
+	} else if err != nil {
+		return nil, err
+	}
+
+	_, err = m.DB.Exec("UPDATE api_tokens SET last_used = datetime('now') WHERE id = ?", t.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// ForUser returns a user's tokens, newest first.
+func (m *TokenModel) ForUser(userID int) ([]*models.Token, error) {
+	stmt := `SELECT id, user_id, name, scope, created, last_used FROM api_tokens
+	WHERE user_id = ? ORDER BY created DESC, id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*models.Token{}
+	for rows.Next() {
+		t := &models.Token{}
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Scope, &t.Created, &t.LastUsed)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Delete revokes one of a user's tokens.
+func (m *TokenModel) Delete(id, userID int) error {
+	result, err := m.DB.Exec("DELETE FROM api_tokens WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
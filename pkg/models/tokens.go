@@ -0,0 +1,55 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+)
+
+// The scopes an API token can have. Write tokens may do everything read
+// tokens can.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// tokenPrefix marks API tokens so they're easy to recognise, for example by
+// secret scanners, when they end up somewhere they shouldn't.
+const tokenPrefix = "sb_"
+
+// Token is an API token a user can authenticate requests with instead of a
+// session cookie. Only a hash of the token itself is ever stored.
+type Token struct {
+	ID      int
+	UserID  int
+	Name    string
+	Scope   string
+	Created time.Time
+	// LastUsed is nil for tokens which have never been used.
+	LastUsed *time.Time
+}
+
+// Allows reports whether the token grants the given scope.
+func (t *Token) Allows(scope string) bool {
+	return t.Scope == scope || t.Scope == ScopeWrite
+}
+
+// NewToken generates a random API token, returning the plain token to show
+// to the user once and the hash to store.
+func NewToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = tokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the hash stored for a token. Tokens are long and random,
+// so a fast unsalted hash is enough to make a leaked table useless.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
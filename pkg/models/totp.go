@@ -0,0 +1,42 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"strconv"
+	"strings"
+)
+
+// RecoveryCodeCount is how many recovery codes a user gets when they turn on
+// two-factor authentication.
+const RecoveryCodeCount = 10
+
+// recoveryEncoding spells recovery codes in lower case base32 without
+// padding, which is easy to read out and type.
+var recoveryEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// NewRecoveryCodes generates n random one-time recovery codes, formatted
+// like "abcde-fghij", to show to the user once.
+func NewRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		b := make([]byte, 7)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		s := recoveryEncoding.EncodeToString(b)[:10]
+		codes[i] = s[:5] + "-" + s[5:]
+	}
+	return codes, nil
+}
+
+// HashRecoveryCode returns the hash stored for one of a user's recovery
+// codes. The user's ID is hashed along with the code, so a code hashes
+// differently for every user and one table of precomputed hashes can't be
+// checked against all of them at once. Case, spaces and dashes are ignored,
+// so the code can be typed however it was written down.
+func HashRecoveryCode(userID int, code string) string {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer(" ", "", "-", "").Replace(code)
+	return HashToken(strconv.Itoa(userID) + ":" + code)
+}
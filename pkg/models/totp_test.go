@@ -0,0 +1,19 @@
+package models
+
+import "testing"
+
+func TestHashRecoveryCode(t *testing.T) {
+	hash := HashRecoveryCode(1, "abcde-fghij")
+
+	for _, code := range []string{"ABCDE-FGHIJ", "abcdefghij", " abcde fghij "} {
+		if got := HashRecoveryCode(1, code); got != hash {
+			t.Errorf("%q hashes differently from abcde-fghij", code)
+		}
+	}
+	if HashRecoveryCode(2, "abcde-fghij") == hash {
+		t.Error("the same code hashes the same for different users")
+	}
+	if HashRecoveryCode(1, "abcde-fghik") == hash {
+		t.Error("different codes hash the same")
+	}
+}
@@ -0,0 +1,110 @@
+// Package ratelimit limits how often something may happen per key, such as
+// requests per client, using token buckets kept in memory.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter hands out tokens from one bucket per key. Each bucket holds up to
+// Burst tokens and refills at Rate tokens per second, so a key may make a
+// short burst of requests but no more than Rate a second on average. It is
+// safe for concurrent use.
+type Limiter struct {
+	Rate  float64
+	Burst int
+
+	now     func() time.Time
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	sweep   time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Result describes the state of a key's bucket after Take.
+type Result struct {
+	Allowed bool
+	// Limit is the bucket size and Remaining the whole tokens left in it.
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until the next token is available, and is
+	// only set when the request wasn't allowed.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// New returns a Limiter allowing rate events per second on average, with
+// bursts of up to burst events.
+func New(rate float64, burst int) *Limiter {
+	return &Limiter{
+		Rate:    rate,
+		Burst:   burst,
+		now:     time.Now,
+		buckets: map[string]*bucket{},
+	}
+}
+
+// Take tries to take a token from key's bucket.
+func (l *Limiter) Take(key string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.last = now
+
+	res := Result{Limit: l.Burst}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = l.wait(1 - b.tokens)
+	}
+	res.Remaining = int(math.Floor(b.tokens))
+	res.Reset = l.wait(float64(l.Burst) - b.tokens)
+
+	return res
+}
+
+// refill returns the tokens in b once it has been topped up for the time
+// since it was last used.
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + now.Sub(b.last).Seconds()*l.Rate
+	return math.Min(tokens, float64(l.Burst))
+}
+
+// wait returns how long the bucket takes to gain n tokens.
+func (l *Limiter) wait(n float64) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return time.Duration(n / l.Rate * float64(time.Second))
+}
+
+// prune drops the buckets which have refilled completely, and so behave
+// just like a missing one, at most once a minute.
+func (l *Limiter) prune(now time.Time) {
+	if now.Before(l.sweep) {
+		return
+	}
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+	l.sweep = now.Add(time.Minute)
+}
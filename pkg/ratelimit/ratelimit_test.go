@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTake(t *testing.T) {
+	// One token a second, in bursts of up to 3.
+	steps := []struct {
+		name    string
+		advance time.Duration
+		want    Result
+	}{
+		{"First of burst", 0, Result{Allowed: true, Limit: 3, Remaining: 2, Reset: time.Second}},
+		{"Second of burst", 0, Result{Allowed: true, Limit: 3, Remaining: 1, Reset: 2 * time.Second}},
+		{"Last of burst", 0, Result{Allowed: true, Limit: 3, Remaining: 0, Reset: 3 * time.Second}},
+		{"Burst spent", 0, Result{Limit: 3, RetryAfter: time.Second, Reset: 3 * time.Second}},
+		{"Part refilled", 500 * time.Millisecond, Result{Limit: 3, RetryAfter: 500 * time.Millisecond, Reset: 2500 * time.Millisecond}},
+		{"Refilled one", 500 * time.Millisecond, Result{Allowed: true, Limit: 3, Remaining: 0, Reset: 3 * time.Second}},
+		{"Refilled two", 2 * time.Second, Result{Allowed: true, Limit: 3, Remaining: 1, Reset: 2 * time.Second}},
+		{"Refill capped at burst", time.Hour, Result{Allowed: true, Limit: 3, Remaining: 2, Reset: time.Second}},
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(1, 3)
+	l.now = func() time.Time { return now }
+
+	for _, s := range steps {
+		now = now.Add(s.advance)
+		if got := l.Take("key"); got != s.want {
+			t.Fatalf("%s: got %+v; want %+v", s.name, got, s.want)
+		}
+	}
+}
+
+func TestTakeKeysAreIndependent(t *testing.T) {
+	l := New(1, 1)
+	l.now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	if !l.Take("a").Allowed {
+		t.Fatal("first take for a refused")
+	}
+	if l.Take("a").Allowed {
+		t.Error("second take for a allowed")
+	}
+	if !l.Take("b").Allowed {
+		t.Error("first take for b refused")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	// One token every 100 seconds, with no bursts.
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(0.01, 1)
+	l.now = func() time.Time { return now }
+
+	l.Take("idle")
+	now = now.Add(30 * time.Second)
+	l.Take("busy")
+
+	// The sweep on the next Take finds "idle" refilled, so it behaves like
+	// a missing bucket and is dropped, while "busy" is still refilling.
+	now = now.Add(70 * time.Second)
+	if res := l.Take("busy"); res.Allowed {
+		t.Errorf("got %+v; want busy to still be limited", res)
+	}
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("refilled bucket wasn't pruned")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Error("refilling bucket was pruned")
+	}
+}
@@ -0,0 +1,148 @@
+// Package throttle slows down and then locks out repeated failures, such as
+// wrong passwords, per key. State is kept in memory, so it is per process and
+// is forgotten on restart.
+package throttle
+
+import (
+	"sync"
+	"time"
+)
+
+// Policy says how failures for a key are punished. The first Free failures
+// cost nothing. After that each failure blocks the key for Base, doubling
+// with every further failure up to Max. Once LockAfter failures have been
+// seen the key is locked out for LockFor. A key's failures are forgotten when
+// it has been left alone for LockFor, which is also how a lockout ends.
+type Policy struct {
+	Free      int
+	Base      time.Duration
+	Max       time.Duration
+	LockAfter int
+	LockFor   time.Duration
+}
+
+type entry struct {
+	failures int
+	last     time.Time
+	until    time.Time
+}
+
+// Throttle tracks failures per key under a Policy. It is safe for concurrent
+// use.
+type Throttle struct {
+	policy  Policy
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]*entry
+	sweep   time.Time
+}
+
+// New returns a Throttle enforcing policy.
+func New(policy Policy) *Throttle {
+	return &Throttle{
+		policy:  policy,
+		now:     time.Now,
+		entries: map[string]*entry{},
+	}
+}
+
+// Allow reports whether an attempt for key may go ahead. If not, it also
+// returns how long until the key is allowed again. An allowed attempt is
+// counted as a failure straight away, so that concurrent attempts can't all
+// get through before the first of them fails; callers take it back with
+// Reset or Refund once the attempt succeeds.
+func (t *Throttle) Allow(key string) (bool, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	t.prune(now)
+
+	e := t.lookup(key, now)
+	if e != nil && now.Before(e.until) {
+		return false, e.until.Sub(now)
+	}
+
+	if e == nil {
+		e = &entry{}
+		t.entries[key] = e
+	}
+	e.failures++
+	e.last = now
+	e.until = now.Add(t.delay(e.failures))
+	return true, 0
+}
+
+// Reset forgets every failure recorded for key, for example after a
+// successful attempt.
+func (t *Throttle) Reset(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, key)
+}
+
+// Refund takes back the attempt last counted for key by Allow, for an attempt
+// which succeeded but shouldn't forgive the key's earlier failures.
+func (t *Throttle) Refund(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[key]
+	if !ok {
+		return
+	}
+	e.failures--
+	if e.failures <= 0 {
+		delete(t.entries, key)
+		return
+	}
+	e.until = e.last.Add(t.delay(e.failures))
+}
+
+// delay returns how long a key is blocked for after its nth failure.
+func (t *Throttle) delay(failures int) time.Duration {
+	switch {
+	case t.policy.LockAfter > 0 && failures >= t.policy.LockAfter:
+		return t.policy.LockFor
+	case failures > t.policy.Free:
+		delay := t.policy.Base << (failures - t.policy.Free - 1)
+		// A large shift overflows to zero or a negative duration.
+		if delay <= 0 || delay > t.policy.Max {
+			delay = t.policy.Max
+		}
+		return delay
+	}
+	return 0
+}
+
+// lookup returns the entry for key, dropping it first if it has cooled down.
+func (t *Throttle) lookup(key string, now time.Time) *entry {
+	e, ok := t.entries[key]
+	if !ok {
+		return nil
+	}
+	if t.expired(e, now) {
+		delete(t.entries, key)
+		return nil
+	}
+	return e
+}
+
+func (t *Throttle) expired(e *entry, now time.Time) bool {
+	return !now.Before(e.until) && now.Sub(e.last) >= t.policy.LockFor
+}
+
+// prune drops cooled down entries, at most once per LockFor, so keys which
+// are never seen again don't pile up.
+func (t *Throttle) prune(now time.Time) {
+	if now.Before(t.sweep) {
+		return
+	}
+	for key, e := range t.entries {
+		if t.expired(e, now) {
+			delete(t.entries, key)
+		}
+	}
+	t.sweep = now.Add(t.policy.LockFor)
+}
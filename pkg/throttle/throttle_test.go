@@ -0,0 +1,183 @@
+package throttle
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// step is one call in a scenario, made after moving the clock on by
+// advance. Only allow reports a result to check.
+type step struct {
+	advance  time.Duration
+	op       string // "allow", "reset" or "refund"
+	wantOK   bool
+	wantWait time.Duration
+}
+
+func TestThrottle(t *testing.T) {
+	policy := Policy{Free: 2, Base: time.Second, Max: 4 * time.Second, LockAfter: 6, LockFor: time.Minute}
+
+	tests := []struct {
+		name   string
+		policy Policy
+		steps  []step
+	}{
+		{"Backoff", policy, []step{
+			{0, "allow", true, 0},
+			{0, "allow", true, 0},
+			{0, "allow", true, 0},
+			{0, "allow", false, time.Second},
+			{time.Second, "allow", true, 0},
+			{time.Second, "allow", false, time.Second},
+			{time.Second, "allow", true, 0},
+			{time.Second, "allow", false, 3 * time.Second},
+		}},
+		{"Backoff capped at Max", Policy{Base: time.Second, Max: 3 * time.Second, LockFor: time.Minute}, []step{
+			{0, "allow", true, 0},
+			{time.Second, "allow", true, 0},
+			{2 * time.Second, "allow", true, 0},
+			{0, "allow", false, 3 * time.Second},
+			{3 * time.Second, "allow", true, 0},
+			{0, "allow", false, 3 * time.Second},
+		}},
+		{"Lockout", policy, []step{
+			{0, "allow", true, 0},
+			{0, "allow", true, 0},
+			{0, "allow", true, 0},
+			{time.Second, "allow", true, 0},
+			{2 * time.Second, "allow", true, 0},
+			{4 * time.Second, "allow", true, 0},
+			{0, "allow", false, time.Minute},
+			{59 * time.Second, "allow", false, time.Second},
+		}},
+		{"Cooldown after lockout", policy, []step{
+			{0, "allow", true, 0},
+			{0, "allow", true, 0},
+			{0, "allow", true, 0},
+			{time.Second, "allow", true, 0},
+			{2 * time.Second, "allow", true, 0},
+			{4 * time.Second, "allow", true, 0},
+			{time.Minute, "allow", true, 0},
+			{0, "allow", true, 0},
+			{0, "allow", true, 0},
+			{0, "allow", false, time.Second},
+		}},
+		{"Cooldown without lockout", policy, []step{
+			{0, "allow", true, 0},
+			{0, "allow", true, 0},
+			{0, "allow", true, 0},
+			{time.Minute, "allow", true, 0},
+			{0, "allow", true, 0},
+			{0, "allow", true, 0},
+			{0, "allow", false, time.Second},
+		}},
+		{"Reset forgets failures", policy, []step{
+			{0, "allow", true, 0},
+			{0, "allow", true, 0},
+			{0, "allow", true, 0},
+			{0, "reset", false, 0},
+			{0, "allow", true, 0},
+			{0, "allow", true, 0},
+			{0, "allow", true, 0},
+			{0, "allow", false, time.Second},
+		}},
+		{"Refund takes back one attempt", policy, []step{
+			{0, "allow", true, 0},
+			{0, "allow", true, 0},
+			{0, "allow", true, 0},
+			{0, "refund", false, 0},
+			{0, "allow", true, 0},
+			{0, "allow", false, time.Second},
+		}},
+		{"Refund shortens the block", Policy{Base: time.Second, Max: time.Minute, LockFor: time.Minute}, []step{
+			{0, "allow", true, 0},
+			{time.Second, "allow", true, 0},
+			{0, "allow", false, 2 * time.Second},
+			{0, "refund", false, 0},
+			{0, "allow", false, time.Second},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			th := New(tt.policy)
+			th.now = func() time.Time { return now }
+
+			for i, s := range tt.steps {
+				now = now.Add(s.advance)
+				switch s.op {
+				case "allow":
+					ok, wait := th.Allow("key")
+					if ok != s.wantOK || wait != s.wantWait {
+						t.Fatalf("step %d: got %t, %s; want %t, %s", i, ok, wait, s.wantOK, s.wantWait)
+					}
+				case "reset":
+					th.Reset("key")
+				case "refund":
+					th.Refund("key")
+				}
+			}
+		})
+	}
+}
+
+func TestThrottleKeysAreIndependent(t *testing.T) {
+	th := New(Policy{Base: time.Minute, Max: time.Minute, LockFor: time.Hour})
+
+	if ok, _ := th.Allow("a"); !ok {
+		t.Fatal("first attempt for a refused")
+	}
+	if ok, _ := th.Allow("a"); ok {
+		t.Error("second attempt for a allowed")
+	}
+	if ok, _ := th.Allow("b"); !ok {
+		t.Error("first attempt for b refused")
+	}
+}
+
+// TestThrottleConcurrentAttempts checks that attempts made at the same time
+// can't all be allowed before any of them fails.
+func TestThrottleConcurrentAttempts(t *testing.T) {
+	th := New(Policy{Free: 3, Base: time.Second, Max: time.Minute, LockAfter: 10, LockFor: time.Hour})
+	now := time.Now()
+	th.now = func() time.Time { return now }
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if ok, _ := th.Allow("key"); ok {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 4 {
+		t.Errorf("%d attempts allowed; want 4", allowed)
+	}
+}
+
+func TestThrottlePrune(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	th := New(Policy{Base: time.Second, Max: time.Second, LockFor: time.Minute})
+	th.now = func() time.Time { return now }
+
+	th.Allow("old")
+	now = now.Add(time.Minute)
+	th.Allow("new")
+
+	if _, ok := th.entries["old"]; ok {
+		t.Error("cooled down key wasn't pruned")
+	}
+	if _, ok := th.entries["new"]; !ok {
+		t.Error("new key is missing")
+	}
+}
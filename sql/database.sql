@@ -1,61 +1,17 @@
--- Create a new UTF-8 `snippetbox` database.
-
--- User root p password default
+-- Bootstrap a MySQL server for snippetbox. The tables themselves are created
+-- by the versioned migrations in pkg/migrations, so after running this script
+-- apply them with:
+--
+--   go run ./cmd/web migrate up
 
+-- Create a new UTF-8 `snippetbox` database.
 CREATE DATABASE snippetbox CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
--- Switch to using the `snippetbox` database.
-USE snippetbox
-
--- Create a `snippets` table.
-CREATE TABLE snippets (
-    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
-    title VARCHAR(100) NOT NULL,
-    content TEXT NOT NULL,
-    created DATETIME NOT NULL,
-    expires DATETIME NOT NULL
-);
-
--- Add an index on the created column.
-CREATE INDEX idx_snippets_created ON snippets(created);
-
-
--- Add some dummy records (which we'll use in the next couple of chapters).
-INSERT INTO snippets (title, content, created, expires) VALUES (
-    'An old silent pond',
-    'An old silent pond...\nA frog jumps into the pond,\nsplash! Silence again',
-    MY_UTC_TIMESTAMP(),
-    DATE_ADD(MY_UTC_TIMESTAMP(), INTERVAL 365 DAY)
-);
-
-
-INSERT INTO snippets (title, content, created, expires) VALUES (
-    'Over the wintry forest',
-    'Over the wintry\nforest, winds howl in rage\nwith no leaves to blow.\n\n',
-    UTC_TIMESTAMP(),
-    DATE_ADD(UTC_TIMESTAMP(), INTERVAL 365 DAY)
-);
-
-INSERT INTO snippets (title, content, created, expires) VALUES (
-    'First autumn morning',
-    'First autumn morning\nthe mirror I stare into\nshows my father''s face.\n\n',
-    UTC_TIMESTAMP(),
-    DATE_ADD(UTC_TIMESTAMP(), INTERVAL 7 DAY)
-);
-
+-- Create the application user. Migrations run as this user too, so it needs
+-- DDL privileges on the database as well as read/write access.
 CREATE USER 'web'@'localhost';
 
-GRANT SELECT, INSERT ON snippetbox.* TO 'web'@'localhost';
+GRANT SELECT, INSERT, UPDATE, DELETE, CREATE, ALTER, DROP, INDEX, REFERENCES
+    ON snippetbox.* TO 'web'@'localhost';
 
 ALTER USER 'web'@'localhost' IDENTIFIED by 'pass';
-
-
-CREATE TABLE users (
-    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
-    name VARCHAR(255) NOT NULL,
-    email VARCHAR(255) NOT NULL,
-    hashed_password CHAR(60) NOT NULL,
-    created DATETIME NOT NULL
-);
-
-ALTER TABLE users ADD CONSTRAINT users_uc_email UNIQUE (email);
\ No newline at end of file